@@ -0,0 +1,487 @@
+// Package analysis resolves the identifiers of a program to the let statements, function
+// parameters and builtins they refer to without running it, and reports undefined, unused
+// and shadowed names and match expressions that do not cover every value.
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+type BindingKind int
+
+const (
+	Let BindingKind = iota
+	Param
+	Builtin
+	Match // bound by the pattern of a match arm
+)
+
+// Binding is a name introduced by a let statement, a function parameter, a match arm or
+// the language
+type Binding struct {
+	Name  string
+	Ident *ast.Identifier // where the name is declared, nil for builtins
+	Kind  BindingKind
+	Value ast.Expression // the bound expression of a let
+	Scope *Scope
+	Index int               // slot of the name in its scope, a later let of the same name reuses it
+	Uses  []*ast.Identifier // identifiers resolved to this binding
+	seq   int               // order of declaration
+}
+
+// Scope mirrors the environments created at run time: one for the program and one per
+// function or macro call and one per match arm, inside a universe scope holding the
+// builtins. Blocks do not introduce a scope.
+type Scope struct {
+	Parent     *Scope
+	Owner      string // the name a function literal was bound to, if any
+	Start, End int    // source offsets covered by the scope
+	Bindings   []*Binding
+	slots      map[string]int
+	arm        bool
+}
+
+func newScope(parent *Scope, owner string, start, end int) *Scope {
+	return &Scope{Parent: parent, Owner: owner, Start: start, End: end, slots: make(map[string]int)}
+}
+
+// Function reports whether s is the scope of a function or macro call
+func (s *Scope) Function() bool {
+	return s.Parent != nil && s.Parent.Parent != nil && !s.arm
+}
+
+// lookup finds the binding name refers to in a use made from s once seq bindings had been
+// declared. Within s only earlier bindings are visible; an enclosing scope may also supply
+// a later binding, since a function body runs after the code that follows it.
+func (s *Scope) lookup(name string, seq int) (*Binding, int) {
+	depth := 0
+	for own := true; s != nil; s, own, depth = s.Parent, false, depth+1 {
+		var later *Binding
+		// a later let in the same scope shadows an earlier one
+		for i := len(s.Bindings) - 1; i >= 0; i-- {
+			b := s.Bindings[i]
+			if b.Name != name {
+				continue
+			}
+			if b.seq < seq {
+				return b, depth
+			}
+			later = b
+		}
+		if later != nil && !own {
+			return later, depth
+		}
+	}
+	return nil, 0
+}
+
+// Slot locates a binding at run time: Depth environments out from the one in use, at Index
+type Slot struct {
+	Depth, Index int
+}
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+// diagnostic codes
+const (
+	Undefined     = "undefined"
+	Unused        = "unused"
+	Shadowed      = "shadowed"
+	NonExhaustive = "non-exhaustive"
+)
+
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Code     string
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+// Info is the result of analysing a program
+type Info struct {
+	Universe    *Scope
+	Global      *Scope
+	Scopes      []*Scope // the global scope followed by every function and arm scope in source order
+	Bindings    []*Binding
+	Idents      []*ast.Identifier // every declaration and use in source order
+	Refs        map[*ast.Identifier]*Binding
+	Slots       map[*ast.Identifier]Slot
+	Diagnostics []Diagnostic // sorted by position
+}
+
+// IdentAt returns the identifier covering offset, including the offset just past its end
+func (info *Info) IdentAt(offset int) *ast.Identifier {
+	for _, ident := range info.Idents {
+		start := ident.Token.Pos.Offset
+		if offset >= start && offset <= start+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// ScopeAt returns the innermost scope containing offset
+func (info *Info) ScopeAt(offset int) *Scope {
+	found := info.Global
+	for _, s := range info.Scopes {
+		if offset > s.Start && offset <= s.End && s.End-s.Start < found.End-found.Start {
+			found = s
+		}
+	}
+	return found
+}
+
+// names that are always defined: the builtins and the macro primitives
+func universe() *Scope {
+	s := newScope(nil, "", 0, 0)
+	names := append(evaluator.BuiltinNames(), "quote", "unquote", "unquote_splice", "macroexpand")
+	sort.Strings(names)
+	for i, name := range names {
+		b := &Binding{Name: name, Kind: Builtin, Scope: s, Index: i, seq: -1}
+		s.Bindings = append(s.Bindings, b)
+		s.slots[name] = i
+	}
+	return s
+}
+
+type resolver struct {
+	*Info
+	current *Scope
+	closers map[int]token.Position
+	uses    []use
+	quoted  bool // inside quote() but outside unquote(), where names belong to the expansion site
+}
+
+// use is an identifier waiting to be resolved once every binding is known
+type use struct {
+	ident *ast.Identifier
+	scope *Scope
+	seq   int
+}
+
+// Analyze resolves the identifiers of program. src is the text it was parsed from and is
+// used to find where function bodies end; without it a function's scope ends at its body's
+// opening brace.
+func Analyze(program *ast.Program, src string) *Info {
+	info := &Info{
+		Universe: universe(),
+		Refs:     make(map[*ast.Identifier]*Binding),
+		Slots:    make(map[*ast.Identifier]Slot),
+	}
+	info.Global = newScope(info.Universe, "", 0, len(src))
+	info.Scopes = append(info.Scopes, info.Global)
+	r := &resolver{Info: info, current: info.Global, closers: lexer.MatchDelimiters(src)}
+	r.walk(program)
+
+	for _, u := range r.uses {
+		b, depth := u.scope.lookup(u.ident.Value, u.seq)
+		if b == nil {
+			r.report(u.ident.Token.Pos, Error, Undefined, "undefined: %s", u.ident.Value)
+			continue
+		}
+		info.Refs[u.ident] = b
+		info.Slots[u.ident] = Slot{Depth: depth, Index: b.Index}
+		b.Uses = append(b.Uses, u.ident)
+	}
+	for _, b := range info.Bindings {
+		// locals must be used, globals may be meant for importers or the REPL
+		if b.Kind == Let && b.Scope.Function() && len(b.Uses) == 0 && !strings.HasPrefix(b.Name, "_") {
+			r.report(b.Ident.Token.Pos, Warning, Unused, "%s declared but not used", b.Name)
+		}
+	}
+
+	sort.Slice(info.Idents, func(i, j int) bool {
+		return info.Idents[i].Token.Pos.Offset < info.Idents[j].Token.Pos.Offset
+	})
+	sort.SliceStable(info.Diagnostics, func(i, j int) bool {
+		return info.Diagnostics[i].Pos.Offset < info.Diagnostics[j].Pos.Offset
+	})
+	return info
+}
+
+func (r *resolver) report(pos token.Position, severity Severity, code, format string, args ...interface{}) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{Pos: pos, Severity: severity, Code: code, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (r *resolver) declare(ident *ast.Identifier, value ast.Expression, kind BindingKind) {
+	if isNil(ident) {
+		return
+	}
+	s := r.current
+	index, redeclared := s.slots[ident.Value]
+	if !redeclared {
+		index = len(s.slots)
+		s.slots[ident.Value] = index
+		if outer, _ := s.Parent.lookup(ident.Value, len(r.Bindings)); outer != nil {
+			if outer.Kind == Builtin {
+				r.report(ident.Token.Pos, Warning, Shadowed, "%s shadows the builtin", ident.Value)
+			} else {
+				r.report(ident.Token.Pos, Warning, Shadowed, "%s shadows the declaration at %s", ident.Value, outer.Ident.Token.Pos)
+			}
+		}
+	}
+	b := &Binding{Name: ident.Value, Ident: ident, Kind: kind, Value: value, Scope: s, Index: index, seq: len(r.Bindings)}
+	s.Bindings = append(s.Bindings, b)
+	r.Bindings = append(r.Bindings, b)
+	r.Idents = append(r.Idents, ident)
+	r.Refs[ident] = b
+	r.Slots[ident] = Slot{Depth: 0, Index: index}
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	r.Idents = append(r.Idents, ident)
+	r.uses = append(r.uses, use{ident, r.current, len(r.Bindings)})
+}
+
+// function declares params, which are names or patterns, in a new scope and walks body in
+// it. Each default value is walked before its parameter is declared, as it is evaluated then.
+func (r *resolver) function(tok token.Token, owner string, params []ast.Expression, defaults []ast.Expression, body *ast.BlockStatement) {
+	s := newScope(r.current, owner, tok.Pos.Offset, tok.Pos.Offset)
+	if !isNil(body) {
+		s.End = body.Token.Pos.Offset
+		if closer, ok := r.closers[body.Token.Pos.Offset]; ok {
+			s.End = closer.Offset
+		}
+	}
+	r.Scopes = append(r.Scopes, s)
+	r.current = s
+	for i, p := range params {
+		if i < len(defaults) {
+			r.walk(defaults[i])
+		}
+		for _, ident := range ast.PatternIdentifiers(p) {
+			r.declare(ident, nil, Param)
+		}
+	}
+	r.walk(body)
+	r.current = s.Parent
+}
+
+// isNil reports whether node is nil, including a nil pointer stored in the interface
+// which a partial parse can leave behind
+func isNil(node ast.Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func (r *resolver) walk(node ast.Node) {
+	if isNil(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			r.walk(s)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			r.walk(s)
+		}
+	case *ast.LetStatement:
+		if r.quoted {
+			r.walk(node.Value)
+			return
+		}
+		if node.Pattern != nil {
+			r.walk(node.Value)
+			for _, ident := range ast.PatternIdentifiers(node.Pattern) {
+				r.declare(ident, nil, Let)
+			}
+			return
+		}
+		// a function can refer to itself, any other value only to earlier bindings
+		switch value := node.Value.(type) {
+		case *ast.FunctionLiteral:
+			r.declare(node.Name, value, Let)
+			if !isNil(value) {
+				r.function(value.Token, node.Name.Value, functionParams(value), value.Defaults, value.Body)
+			}
+		case *ast.MacroLiteral:
+			r.declare(node.Name, value, Let)
+			if !isNil(value) {
+				r.function(value.Token, node.Name.Value, macroParams(value), nil, value.Body)
+			}
+		default:
+			r.walk(node.Value)
+			r.declare(node.Name, node.Value, Let)
+		}
+	case *ast.ExportStatement:
+		r.walk(node.Statement)
+	case *ast.ReturnStatement:
+		r.walk(node.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.walk(node.Expression)
+	case *ast.Identifier:
+		if !r.quoted {
+			r.use(node)
+		}
+	case *ast.PrefixExpression:
+		r.walk(node.Right)
+	case *ast.InfixExpression:
+		r.walk(node.Left)
+		r.walk(node.Right)
+	case *ast.IfExpression:
+		r.walk(node.Condition)
+		r.walk(node.Consequence)
+		r.walk(node.Alternative)
+	case *ast.FunctionLiteral:
+		if r.quoted {
+			for _, def := range node.Defaults {
+				r.walk(def)
+			}
+			r.walk(node.Body)
+			return
+		}
+		r.function(node.Token, "", functionParams(node), node.Defaults, node.Body)
+	case *ast.MacroLiteral:
+		r.function(node.Token, "", macroParams(node), nil, node.Body)
+	case *ast.SpreadExpression:
+		r.walk(node.Value)
+	case *ast.CallExpression:
+		r.call(node)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.walk(el)
+		}
+	case *ast.IndexExpression:
+		r.walk(node.Left)
+		r.walk(node.Index)
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			r.walk(part)
+		}
+	case *ast.SliceExpression:
+		r.walk(node.Left)
+		r.walk(node.Start)
+		r.walk(node.End)
+		r.walk(node.Step)
+	case *ast.MemberExpression:
+		r.walk(node.Object) // the property is a name of the value, not a variable
+	case *ast.HashLiteral:
+		for _, key := range node.Keys() {
+			r.walk(key)
+			r.walk(node.Pairs[key])
+		}
+	case *ast.MatchExpression:
+		r.match(node)
+	}
+}
+
+// match walks each arm in a scope of its own holding the names bound by its pattern, and
+// reports a match that some values of the subject fall through
+func (r *resolver) match(node *ast.MatchExpression) {
+	r.walk(node.Subject)
+	if r.quoted {
+		for _, arm := range node.Arms {
+			r.walk(arm.Guard)
+			r.walk(arm.Body)
+		}
+		return
+	}
+	end := r.matchEnd(node)
+	for i, arm := range node.Arms {
+		if isNil(arm) || isNil(arm.Pattern) {
+			continue
+		}
+		s := newScope(r.current, "", arm.Pattern.Pos().Offset, end)
+		if i < len(node.Arms)-1 && !isNil(node.Arms[i+1]) && !isNil(node.Arms[i+1].Pattern) {
+			s.End = node.Arms[i+1].Pattern.Pos().Offset - 1
+		}
+		s.arm = true
+		r.Scopes = append(r.Scopes, s)
+		r.current = s
+		for _, ident := range ast.PatternIdentifiers(arm.Pattern) {
+			r.declare(ident, nil, Match)
+		}
+		r.walk(arm.Guard)
+		r.walk(arm.Body)
+		r.current = s.Parent
+	}
+	if missing := unmatched(node); missing != "" {
+		r.report(node.Token.Pos, Warning, NonExhaustive, "match is not exhaustive: %s", missing)
+	}
+}
+
+// matchEnd returns the offset of the brace closing a match. The AST does not keep the
+// braces, so they are found as the delimiters following the match keyword and the subject.
+func (r *resolver) matchEnd(node *ast.MatchExpression) int {
+	if closer, ok := lexer.BlockCloser(r.closers, node.Token.Pos.Offset); ok {
+		return closer.Offset
+	}
+	return node.Token.Pos.Offset
+}
+
+// functionParams returns the parameters of a function, patterns in place of the names
+// they replace, followed by its rest parameter
+func functionParams(fn *ast.FunctionLiteral) []ast.Expression {
+	params := []ast.Expression{}
+	for i, param := range fn.Parameters {
+		if pattern := fn.Pattern(i); pattern != nil {
+			params = append(params, pattern)
+		} else if param != nil {
+			params = append(params, param)
+		}
+	}
+	if fn.Rest != nil {
+		params = append(params, fn.Rest)
+	}
+	return params
+}
+
+// macroParams returns the parameters of a macro followed by its rest parameter
+func macroParams(m *ast.MacroLiteral) []ast.Expression {
+	params := []ast.Expression{}
+	for _, param := range m.Parameters {
+		if param != nil {
+			params = append(params, param)
+		}
+	}
+	if m.Rest != nil {
+		params = append(params, m.Rest)
+	}
+	return params
+}
+
+// call walks a call, switching in and out of quoted code for quote, unquote and
+// unquote_splice
+func (r *resolver) call(node *ast.CallExpression) {
+	quoted := r.quoted
+	if ident, ok := node.Function.(*ast.Identifier); ok {
+		switch {
+		case ident.Value == "quote" && !quoted:
+			r.walk(ident)
+			r.quoted = true
+		case (ident.Value == "unquote" || ident.Value == "unquote_splice") && quoted:
+			r.quoted = false
+			r.walk(ident)
+		default:
+			r.walk(ident)
+		}
+	} else {
+		r.walk(node.Function)
+	}
+	for _, arg := range node.Arguments {
+		r.walk(arg)
+	}
+	r.quoted = quoted
+}
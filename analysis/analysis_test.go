@@ -0,0 +1,181 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+func analyze(t *testing.T, src string) (*ast.Program, *Info) {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program, Analyze(program, src)
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"let f = fn(a) { a + b }; f(1)", []string{"1:21: undefined: b"}},
+		{"if (false) { pritn(1) }", []string{"1:14: undefined: pritn"}},
+		// a let cannot refer to itself, unlike a function
+		{"let x = x + 1;", []string{"1:9: undefined: x"}},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)", nil},
+		// functions run later, so they may refer to globals declared after them
+		{"let f = fn() { g() }; let g = fn() { 1 }; f()", nil},
+		{"let f = fn() { let unused = 1; 2 }; f()", []string{"1:20: unused declared but not used"}},
+		{"let f = fn() { let _ignored = 1; 2 }; f()", nil},
+		// globals and parameters are never reported as unused
+		{"let a = 1; let f = fn(x) { 2 };", nil},
+		{"let x = 1; let f = fn() { let x = 2; x }; f()", []string{"1:31: x shadows the declaration at 1:5"}},
+		{"let x = 1; let f = fn(x) { x }; f(x)", []string{"1:23: x shadows the declaration at 1:5"}},
+		{"let len = fn(a) { 0 }; len([])", []string{"1:5: len shadows the builtin"}},
+		// declaring a name again in the same scope replaces it rather than shadowing it
+		{"let x = 1; let x = x + 1; x", nil},
+		// names inside quote belong to the call site, except inside unquote
+		{"let m = macro(a) { quote(unquote(a) + b) }; m(1)", nil},
+		{"let m = macro(a) { quote(unquote(c)) };", []string{"1:34: undefined: c"}},
+		{"let m = macro(...args) { quote(f(unquote_splice(args), g)) }; m(1)", nil},
+		{"let m = macro(...args) { quote(unquote_splice(others)) };", []string{"1:47: undefined: others"}},
+		{"let m = macro(a) { quote(unquote(a)) }; macroexpand(quote(m(1)))", nil},
+		// patterns declare each name they bind
+		{"let [a, {b, c: d}] = x; a + b + d", []string{"1:22: undefined: x"}},
+		{"let f = fn([a], {b}) { a + b + c }; f", []string{"1:32: undefined: c"}},
+		// a default value sees the parameters before it
+		{"let f = fn(a, b = a, c = d) { b + c }; f(1)", []string{"1:26: undefined: d"}},
+		{"let f = fn(a, ...more) { len(more) + a }; f(1)", nil},
+		{"let m = import \"m\"; m[\"f\"](1)", nil},
+		{"let a = [1]; a[i:j:k]", []string{"1:16: undefined: i", "1:18: undefined: j", "1:20: undefined: k"}},
+		// the expressions of a template string use the names in scope
+		{"let f = fn() { let n = 1; \"${n} of ${total}\" }; f()", []string{"1:38: undefined: total"}},
+		// properties and methods are not variables
+		{"let h = {}; h.name.upper(); k.len()", []string{"1:29: undefined: k"}},
+		// the names bound by a match arm are only visible in that arm
+		{"let f = fn(x) { match (x) { [a, _] if a => a, b => a + b } }; f", []string{"1:52: undefined: a"}},
+		{"let f = fn(b) { match (b) { true => 1, false => 0 } }; f", nil},
+		{"let f = fn(b) { match (b == 1) { true => 1 } }; f", []string{"1:17: match is not exhaustive: false is not matched"}},
+		{"let f = fn(b) { match (b) { true => 1, false if b => 0 } }; f", []string{"1:17: match is not exhaustive: false is not matched"}},
+		{"let f = fn(x) { match (x) { 1 => 1, 2 => 2 } }; f", []string{"1:17: match is not exhaustive: add an arm with _ to match every other value"}},
+		{"let f = fn(xs) { match (xs) { [] => 0, [a, ...r] => a } }; f", nil},
+		{"let f = fn(xs) { match (xs) { [] => 0, [a] => a, [a, b, ...r] => b } }; f", nil},
+		{"let f = fn(xs) { match (xs) { [] => 0, [a, b, ...r] => b } }; f", []string{"1:18: match is not exhaustive: an array of 1 elements is not matched"}},
+		{"let f = fn(xs) { match (xs) { [0] => 0, [a] => a } }; f", []string{"1:18: match is not exhaustive: an array of 0 elements is not matched"}},
+		{"let f = fn(h) { match (h) { {a} => a, {} => 0 } }; f", nil},
+	}
+
+	for _, tt := range tests {
+		_, info := analyze(t, tt.input)
+		if len(info.Diagnostics) != len(tt.expected) {
+			t.Errorf("%q: expected %d diagnostics, got %v", tt.input, len(tt.expected), info.Diagnostics)
+			continue
+		}
+		for i, want := range tt.expected {
+			if got := info.Diagnostics[i].String(); got != want {
+				t.Errorf("%q: expected %q, got %q", tt.input, want, got)
+			}
+		}
+	}
+}
+
+func TestSeverityAndCode(t *testing.T) {
+	_, info := analyze(t, "let f = fn(x) { let y = 1; z }; let puts = 1;")
+	expected := []struct {
+		severity Severity
+		code     string
+	}{
+		{Warning, Unused},
+		{Error, Undefined},
+		{Warning, Shadowed},
+	}
+	if len(info.Diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), info.Diagnostics)
+	}
+	for i, want := range expected {
+		got := info.Diagnostics[i]
+		if got.Severity != want.severity || got.Code != want.code {
+			t.Errorf("diagnostic %d: expected %v %s, got %v %s", i, want.severity, want.code, got.Severity, got.Code)
+		}
+	}
+}
+
+func TestReferencesAndSlots(t *testing.T) {
+	src := `let a = 1;
+let b = 2;
+let f = fn(x, y) {
+  let g = fn() { x + b };
+  g() + y + len("")
+};
+let a = f(a, 3);`
+	_, info := analyze(t, src)
+	if len(info.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", info.Diagnostics)
+	}
+
+	// each use, identified by position, and the binding and slot it resolves to
+	tests := []struct {
+		pos      string
+		name     string
+		declared string
+		slot     Slot
+	}{
+		{"4:18", "x", "3:12", Slot{1, 0}},
+		{"4:22", "b", "2:5", Slot{2, 1}},
+		{"5:3", "g", "4:7", Slot{0, 2}},
+		{"5:9", "y", "3:15", Slot{0, 1}},
+		{"5:13", "len", "", Slot{2, 13}},
+		{"7:9", "f", "3:5", Slot{0, 2}},
+		// the second let of a reuses the slot of the first
+		{"7:5", "a", "7:5", Slot{0, 0}},
+		{"7:11", "a", "1:5", Slot{0, 0}},
+	}
+	for _, tt := range tests {
+		var ident *ast.Identifier
+		for _, id := range info.Idents {
+			if id.Token.Pos.String() == tt.pos {
+				ident = id
+			}
+		}
+		if ident == nil || ident.Value != tt.name {
+			t.Errorf("no identifier %s at %s", tt.name, tt.pos)
+			continue
+		}
+		b := info.Refs[ident]
+		if b == nil {
+			t.Errorf("%s at %s is unresolved", tt.name, tt.pos)
+			continue
+		}
+		declared := ""
+		if b.Ident != nil {
+			declared = b.Ident.Token.Pos.String()
+		}
+		if declared != tt.declared {
+			t.Errorf("%s at %s: expected the declaration at %q, got %q", tt.name, tt.pos, tt.declared, declared)
+		}
+		if slot := info.Slots[ident]; slot != tt.slot {
+			t.Errorf("%s at %s: expected slot %+v, got %+v", tt.name, tt.pos, tt.slot, slot)
+		}
+	}
+}
+
+func TestScopeAt(t *testing.T) {
+	src := "let f = fn(x) {\n  x\n};\nf(1)"
+	_, info := analyze(t, src)
+	if len(info.Scopes) != 2 {
+		t.Fatalf("expected 2 scopes, got %d", len(info.Scopes))
+	}
+	fn := info.Scopes[1]
+	if fn.Owner != "f" || !fn.Function() || info.Global.Function() {
+		t.Errorf("wrong scopes %+v", info.Scopes)
+	}
+	if info.ScopeAt(18) != fn || info.ScopeAt(len(src)) != info.Global {
+		t.Error("ScopeAt returned the wrong scope")
+	}
+}
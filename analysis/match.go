@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/josh-weston/go_interpreter/ast"
+)
+
+// unmatched describes values that no arm of a match covers, or returns "" if every value
+// is covered or the analysis cannot tell. Booleans and arrays are checked case by case;
+// any other subject needs an arm that matches everything.
+func unmatched(node *ast.MatchExpression) string {
+	var arms []*ast.MatchArm // the arms without a guard, the only ones known to match
+	for _, arm := range node.Arms {
+		if isNil(arm) || isNil(arm.Pattern) {
+			return ""
+		}
+		if arm.Guard == nil {
+			arms = append(arms, arm)
+		}
+	}
+	for _, arm := range arms {
+		if _, ok := arm.Pattern.(*ast.Identifier); ok {
+			return ""
+		}
+	}
+
+	switch subjectKind(node) {
+	case "BOOLEAN":
+		covered := map[bool]bool{}
+		for _, arm := range arms {
+			if b, ok := arm.Pattern.(*ast.Boolean); ok {
+				covered[b.Value] = true
+			}
+		}
+		for _, value := range []bool{true, false} {
+			if !covered[value] {
+				return fmt.Sprintf("%t is not matched", value)
+			}
+		}
+		return ""
+	case "ARRAY":
+		if n, ok := unmatchedLength(arms); ok {
+			return fmt.Sprintf("an array of %d elements is not matched", n)
+		}
+		return ""
+	case "HASH":
+		for _, arm := range arms {
+			if hash, ok := arm.Pattern.(*ast.HashPattern); ok && len(hash.Keys) == 0 {
+				return ""
+			}
+		}
+	}
+	return "add an arm with _ to match every other value"
+}
+
+// subjectKind returns the type the subject of a match evaluates to when it can be told
+// from the subject itself or, failing that, from patterns that all expect the same type
+func subjectKind(node *ast.MatchExpression) string {
+	switch subject := node.Subject.(type) {
+	case *ast.Boolean:
+		return "BOOLEAN"
+	case *ast.PrefixExpression:
+		if subject.Operator == "!" {
+			return "BOOLEAN"
+		}
+	case *ast.InfixExpression:
+		switch subject.Operator {
+		case "==", "!=", "<", ">":
+			return "BOOLEAN"
+		}
+	case *ast.ArrayLiteral:
+		return "ARRAY"
+	case *ast.HashLiteral:
+		return "HASH"
+	}
+
+	kind := ""
+	for _, arm := range node.Arms {
+		var armKind string
+		switch arm.Pattern.(type) {
+		case *ast.Boolean:
+			armKind = "BOOLEAN"
+		case *ast.ArrayPattern:
+			armKind = "ARRAY"
+		case *ast.HashPattern:
+			armKind = "HASH"
+		default:
+			return ""
+		}
+		if kind != "" && kind != armKind {
+			return ""
+		}
+		kind = armKind
+	}
+	return kind
+}
+
+// unmatchedLength returns the smallest length of array that none of the array patterns
+// of arms is sure to match. Only patterns whose elements are all names match every
+// array of their length.
+func unmatchedLength(arms []*ast.MatchArm) (int, bool) {
+	exact := map[int]bool{}
+	atLeast := -1 // the shortest length matched by a pattern with a rest element
+	for _, arm := range arms {
+		pattern, ok := arm.Pattern.(*ast.ArrayPattern)
+		if !ok || !irrefutable(pattern.Elements) {
+			continue
+		}
+		n := len(pattern.Elements)
+		if pattern.Rest == nil {
+			exact[n] = true
+		} else if atLeast == -1 || n < atLeast {
+			atLeast = n
+		}
+	}
+	for n := 0; atLeast == -1 || n < atLeast; n++ {
+		if !exact[n] {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func irrefutable(patterns []ast.Expression) bool {
+	for _, pattern := range patterns {
+		if _, ok := pattern.(*ast.Identifier); !ok {
+			return false
+		}
+	}
+	return true
+}
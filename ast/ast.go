@@ -1,6 +1,9 @@
 package ast
 
 import (
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/josh-weston/go_interpreter/token"
@@ -9,6 +12,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first token of the node
 }
 
 type Statement interface {
@@ -32,6 +36,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var sb strings.Builder
 	for _, s := range p.Statements {
@@ -41,17 +52,23 @@ func (p *Program) String() string {
 }
 
 type LetStatement struct {
-	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // the token.LET token
+	Name    *Identifier
+	Pattern Expression // an ArrayPattern or HashPattern taking Value apart, used instead of Name
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}                          // satisfy the statement interface
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal } // satisfy the node interface
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var sb strings.Builder
 	sb.WriteString(ls.TokenLiteral() + " ")
-	sb.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		sb.WriteString(ls.Pattern.String())
+	} else {
+		sb.WriteString(ls.Name.String())
+	}
 	sb.WriteString(" = ") // manually added
 
 	if ls.Value != nil {
@@ -68,6 +85,7 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}                         // satisfy the expression interface
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal } // satisfy the node interface
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
 func (i *Identifier) String() string       { return i.Value }
 
 type ReturnStatement struct {
@@ -77,6 +95,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}                          // satisfy the statement interface
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal } // satisfy the node interface
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var sb strings.Builder
 	sb.WriteString(rs.TokenLiteral() + " ")
@@ -95,6 +114,12 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}                          // satisfy the statement interface
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal } // satisfy the node interface
+func (es *ExpressionStatement) Pos() token.Position {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -109,8 +134,28 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) String() string       { return FormatFloat(fl.Value) }
+
+// FormatFloat returns v as the source of a float literal, which always has a fraction
+func FormatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") && !math.IsInf(v, 0) && !math.IsNaN(v) {
+		s += ".0"
+	}
+	return s
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -118,8 +163,33 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// InterpolatedString is a template string, "Hello ${name}!". Its parts are the StringLiterals
+// of the text between the expressions, which are converted to strings as by str().
+type InterpolatedString struct {
+	Token token.Token // the TEMPLATE token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) String() string {
+	var sb strings.Builder
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			sb.WriteString(text.Value)
+			continue
+		}
+		sb.WriteString("${")
+		sb.WriteString(part.String())
+		sb.WriteString("}")
+	}
+	return sb.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token // the prefix token (e.g., !)
 	Operator string
@@ -128,6 +198,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var sb strings.Builder
 	sb.WriteString("(")
@@ -146,6 +217,12 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
 func (ie *InfixExpression) String() string {
 	var sb strings.Builder
 	sb.WriteString("(")
@@ -163,8 +240,19 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+// NullLiteral is the null keyword, which evaluates to NULL
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) Pos() token.Position  { return nl.Token.Pos }
+func (nl *NullLiteral) String() string       { return "null" }
+
 type IfExpression struct {
 	Token       token.Token // The 'if' token
 	Condition   Expression
@@ -174,6 +262,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var sb strings.Builder
 	sb.WriteString("if")
@@ -195,6 +284,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) expressionNode()      {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var sb strings.Builder
 	for _, s := range bs.Statements {
@@ -204,18 +294,50 @@ func (bs *BlockStatement) String() string {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token // the 'fn' token
-	Parameters []*Identifier
+	Token      token.Token   // the 'fn' token
+	Parameters []*Identifier // nil where the parameter is a pattern
+	Patterns   []Expression  // the pattern taking each argument apart, nil where there is none
+	Defaults   []Expression  // the default value of each parameter, nil where there is none
+	Rest       *Identifier   // collects the remaining arguments, nil if the function is not variadic
 	Body       *BlockStatement
 }
 
+// Pattern returns the pattern of the i-th parameter, or nil if it is a plain name
+func (fl *FunctionLiteral) Pattern(i int) Expression {
+	if i < len(fl.Patterns) {
+		return fl.Patterns[i]
+	}
+	return nil
+}
+
+// Default returns the default value of the i-th parameter, or nil if it has none
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i < len(fl.Defaults) {
+		return fl.Defaults[i]
+	}
+	return nil
+}
+
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var sb strings.Builder
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		param := ""
+		if pattern := fl.Pattern(i); pattern != nil {
+			param = pattern.String()
+		} else {
+			param = p.String()
+		}
+		if def := fl.Default(i); def != nil {
+			param += " = " + def.String()
+		}
+		params = append(params, param)
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 	sb.WriteString(fl.TokenLiteral())
 	sb.WriteString("(")
@@ -230,10 +352,17 @@ type CallExpression struct {
 	Token     token.Token // the '(' token
 	Function  Expression  // identifier or functionLiteral
 	Arguments []Expression
+	Optional  bool // f?.(x), which gives NULL without calling anything when f is NULL
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
 func (ce *CallExpression) String() string {
 	var sb strings.Builder
 	args := []string{}
@@ -241,6 +370,9 @@ func (ce *CallExpression) String() string {
 		args = append(args, a.String())
 	}
 	sb.WriteString(ce.Function.String())
+	if ce.Optional {
+		sb.WriteString("?.")
+	}
 	sb.WriteString("(")
 	sb.WriteString(strings.Join(args, ", "))
 	sb.WriteString(")")
@@ -254,6 +386,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var sb strings.Builder
 	elements := []string{}
@@ -267,23 +400,105 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // the '[' token
-	Left  Expression  // an expression that produces an object being accessed
-	Index Expression  // an expression that produces an integer
+	Token    token.Token // the '[' token
+	Left     Expression  // an expression that produces an object being accessed
+	Index    Expression  // an expression that produces an integer
+	Optional bool        // a?.[k], which gives NULL without evaluating the index when a is NULL
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
 func (ie *IndexExpression) String() string {
 	var sb strings.Builder
 	sb.WriteString("(")
 	sb.WriteString(ie.Left.String())
+	if ie.Optional {
+		sb.WriteString("?.")
+	}
 	sb.WriteString("[")
 	sb.WriteString(ie.Index.String())
 	sb.WriteString("])")
 	return sb.String()
 }
 
+// SliceExpression takes part of an array or string: a[start:end:step], where any of the
+// three may be left out
+type SliceExpression struct {
+	Token    token.Token // the '[' token
+	Left     Expression
+	Start    Expression // nil if left out
+	End      Expression // nil if left out
+	Step     Expression // nil if left out
+	Optional bool       // a?.[1:], which gives NULL when a is NULL
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position {
+	if se.Left != nil {
+		return se.Left.Pos()
+	}
+	return se.Token.Pos
+}
+func (se *SliceExpression) String() string {
+	bound := func(exp Expression) string {
+		if exp == nil {
+			return ""
+		}
+		return exp.String()
+	}
+	var sb strings.Builder
+	sb.WriteString("(")
+	sb.WriteString(se.Left.String())
+	if se.Optional {
+		sb.WriteString("?.")
+	}
+	sb.WriteString("[")
+	sb.WriteString(bound(se.Start) + ":" + bound(se.End))
+	if se.Step != nil {
+		sb.WriteString(":" + se.Step.String())
+	}
+	sb.WriteString("])")
+	return sb.String()
+}
+
+// MemberExpression reads a field of a hash or an export of a module, h.key being short
+// for h["key"]. As the function of a call it calls a method of the value: s.upper()
+type MemberExpression struct {
+	Token    token.Token // the '.' token, or '?.' if Optional
+	Object   Expression
+	Property *Identifier
+	Optional bool // a?.key, which gives NULL when a is NULL
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position {
+	if me.Object != nil {
+		return me.Object.Pos()
+	}
+	return me.Token.Pos
+}
+func (me *MemberExpression) String() string {
+	var sb strings.Builder
+	sb.WriteString("(")
+	sb.WriteString(me.Object.String())
+	if me.Optional {
+		sb.WriteString("?.")
+	} else {
+		sb.WriteString(".")
+	}
+	sb.WriteString(me.Property.String())
+	sb.WriteString(")")
+	return sb.String()
+}
+
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
@@ -291,11 +506,12 @@ type HashLiteral struct {
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var sb strings.Builder
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	sb.WriteString("{")
 	sb.WriteString(strings.Join(pairs, ", "))
@@ -303,20 +519,42 @@ func (hl *HashLiteral) String() string {
 	return sb.String()
 }
 
+// Keys returns the keys of the hash in source order, or alphabetical order for keys that
+// were not parsed, so that traversals are deterministic
+func (hl *HashLiteral) Keys() []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := keys[i].Pos(), keys[j].Pos()
+		if pi.IsValid() && pj.IsValid() {
+			return pi.Offset < pj.Offset
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
 type MacroLiteral struct {
 	Token      token.Token // the 'macro' token
 	Parameters []*Identifier
+	Rest       *Identifier // collects the remaining arguments, nil if the macro is not variadic
 	Body       *BlockStatement
 }
 
 func (ml *MacroLiteral) expressionNode()      {}
 func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
 func (ml *MacroLiteral) String() string {
 	var sb strings.Builder
 	params := []string{}
 	for _, p := range ml.Parameters {
 		params = append(params, p.String())
 	}
+	if ml.Rest != nil {
+		params = append(params, "..."+ml.Rest.String())
+	}
 	sb.WriteString(ml.TokenLiteral())
 	sb.WriteString("(")
 	sb.WriteString(strings.Join(params, ", "))
@@ -324,3 +562,170 @@ func (ml *MacroLiteral) String() string {
 	sb.WriteString(ml.Body.String())
 	return sb.String()
 }
+
+// ArrayPattern binds the elements of an array: let [a, [b, c], ...rest] = arr;
+type ArrayPattern struct {
+	Token    token.Token  // the '[' token
+	Elements []Expression // identifiers and nested patterns
+	Rest     *Identifier  // collects the remaining elements, nil if there is none
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern binds the values of a hash by their string keys: let {name, address: {city}} = person;
+type HashPattern struct {
+	Token  token.Token   // the '{' token
+	Keys   []*Identifier // in source order
+	Values []Expression  // the pattern for each key, nil where the key itself is bound
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) String() string {
+	entries := []string{}
+	for i, key := range hp.Keys {
+		if value := hp.Value(i); value != nil {
+			entries = append(entries, key.String()+": "+value.String())
+			continue
+		}
+		entries = append(entries, key.String())
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// Value returns the pattern for the i-th key, or nil if the key itself is bound
+func (hp *HashPattern) Value(i int) Expression {
+	if i < len(hp.Values) {
+		return hp.Values[i]
+	}
+	return nil
+}
+
+// MatchExpression evaluates the body of the first arm whose pattern matches the subject:
+// match (x) { 0 => "zero", [a, ...rest] if a > 0 => rest, _ => x }
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match (" + me.Subject.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
+// MatchArm is a pattern of a match expression, with an optional guard, and the body
+// evaluated when they match. The pattern may hold literals and _ besides the names and
+// nested patterns of a let.
+type MatchArm struct {
+	Token   token.Token // the '=>' token
+	Pattern Expression
+	Guard   Expression // nil if there is none
+	Body    Expression
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) Pos() token.Position  { return ma.Token.Pos }
+func (ma *MatchArm) String() string {
+	var sb strings.Builder
+	sb.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		sb.WriteString(" if " + ma.Guard.String())
+	}
+	sb.WriteString(" => ")
+	sb.WriteString(ma.Body.String())
+	return sb.String()
+}
+
+// IsWildcard reports whether a pattern is _, which matches anything without binding it
+func IsWildcard(pattern Expression) bool {
+	ident, ok := pattern.(*Identifier)
+	return ok && ident.Value == "_"
+}
+
+// PatternIdentifiers returns the identifiers bound by a pattern, in source order. An
+// identifier is a pattern binding itself.
+func PatternIdentifiers(pattern Expression) []*Identifier {
+	var idents []*Identifier
+	var collect func(Expression)
+	collect = func(pattern Expression) {
+		switch pattern := pattern.(type) {
+		case *Identifier:
+			if !IsWildcard(pattern) {
+				idents = append(idents, pattern)
+			}
+		case *ArrayPattern:
+			for _, el := range pattern.Elements {
+				collect(el)
+			}
+			if pattern.Rest != nil {
+				collect(pattern.Rest)
+			}
+		case *HashPattern:
+			for i, key := range pattern.Keys {
+				if value := pattern.Value(i); value != nil {
+					collect(value)
+				} else {
+					collect(key)
+				}
+			}
+		}
+	}
+	collect(pattern)
+	return idents
+}
+
+// SpreadExpression passes the elements of an array as separate arguments: f(...args)
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+type ImportExpression struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " \"" + ie.Path.Value + "\""
+}
+
+// ExportStatement marks a top-level let binding as visible to importing modules
+type ExportStatement struct {
+	Token     token.Token // the 'export' token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
@@ -0,0 +1,195 @@
+package ast
+
+// Clone returns a deep copy of node sharing nothing with it but the immutable tokens, so
+// either can be modified without affecting the other. Nil children stay nil.
+func Clone(node Node) Node {
+	if isNil(node) {
+		return nil
+	}
+	switch node := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(node.Statements)}
+	case *LetStatement:
+		return &LetStatement{
+			Token:   node.Token,
+			Name:    cloneIdentifier(node.Name),
+			Pattern: cloneExpression(node.Pattern),
+			Value:   cloneExpression(node.Value),
+		}
+	case *ExportStatement:
+		export := &ExportStatement{Token: node.Token}
+		if node.Statement != nil {
+			export.Statement = Clone(node.Statement).(*LetStatement)
+		}
+		return export
+	case *ReturnStatement:
+		return &ReturnStatement{Token: node.Token, ReturnValue: cloneExpression(node.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: node.Token, Expression: cloneExpression(node.Expression)}
+	case *BlockStatement:
+		return &BlockStatement{Token: node.Token, Statements: cloneStatements(node.Statements)}
+	case *Identifier:
+		copied := *node
+		return &copied
+	case *IntegerLiteral:
+		copied := *node
+		return &copied
+	case *FloatLiteral:
+		copied := *node
+		return &copied
+	case *StringLiteral:
+		copied := *node
+		return &copied
+	case *Boolean:
+		copied := *node
+		return &copied
+	case *NullLiteral:
+		copied := *node
+		return &copied
+	case *PrefixExpression:
+		return &PrefixExpression{Token: node.Token, Operator: node.Operator, Right: cloneExpression(node.Right)}
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    node.Token,
+			Left:     cloneExpression(node.Left),
+			Operator: node.Operator,
+			Right:    cloneExpression(node.Right),
+		}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   cloneExpression(node.Condition),
+			Consequence: cloneBlock(node.Consequence),
+			Alternative: cloneBlock(node.Alternative),
+		}
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:      node.Token,
+			Parameters: cloneIdentifiers(node.Parameters),
+			Patterns:   cloneExpressions(node.Patterns),
+			Defaults:   cloneExpressions(node.Defaults),
+			Rest:       cloneIdentifier(node.Rest),
+			Body:       cloneBlock(node.Body),
+		}
+	case *SpreadExpression:
+		return &SpreadExpression{Token: node.Token, Value: cloneExpression(node.Value)}
+	case *ArrayPattern:
+		return &ArrayPattern{Token: node.Token, Elements: cloneExpressions(node.Elements), Rest: cloneIdentifier(node.Rest)}
+	case *MatchExpression:
+		match := &MatchExpression{Token: node.Token, Subject: cloneExpression(node.Subject)}
+		if node.Arms != nil {
+			match.Arms = make([]*MatchArm, len(node.Arms))
+			for i, arm := range node.Arms {
+				if arm != nil {
+					match.Arms[i] = Clone(arm).(*MatchArm)
+				}
+			}
+		}
+		return match
+	case *MatchArm:
+		return &MatchArm{
+			Token:   node.Token,
+			Pattern: cloneExpression(node.Pattern),
+			Guard:   cloneExpression(node.Guard),
+			Body:    cloneExpression(node.Body),
+		}
+	case *HashPattern:
+		return &HashPattern{Token: node.Token, Keys: cloneIdentifiers(node.Keys), Values: cloneExpressions(node.Values)}
+	case *MacroLiteral:
+		return &MacroLiteral{
+			Token:      node.Token,
+			Parameters: cloneIdentifiers(node.Parameters),
+			Rest:       cloneIdentifier(node.Rest),
+			Body:       cloneBlock(node.Body),
+		}
+	case *CallExpression:
+		return &CallExpression{Token: node.Token, Function: cloneExpression(node.Function), Arguments: cloneExpressions(node.Arguments), Optional: node.Optional}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: cloneExpressions(node.Elements)}
+	case *IndexExpression:
+		return &IndexExpression{Token: node.Token, Left: cloneExpression(node.Left), Index: cloneExpression(node.Index), Optional: node.Optional}
+	case *InterpolatedString:
+		return &InterpolatedString{Token: node.Token, Parts: cloneExpressions(node.Parts)}
+	case *SliceExpression:
+		return &SliceExpression{
+			Token:    node.Token,
+			Left:     cloneExpression(node.Left),
+			Start:    cloneExpression(node.Start),
+			End:      cloneExpression(node.End),
+			Step:     cloneExpression(node.Step),
+			Optional: node.Optional,
+		}
+	case *MemberExpression:
+		return &MemberExpression{Token: node.Token, Object: cloneExpression(node.Object), Property: cloneIdentifier(node.Property), Optional: node.Optional}
+	case *HashLiteral:
+		hash := &HashLiteral{Token: node.Token, Pairs: make(map[Expression]Expression, len(node.Pairs))}
+		for key, value := range node.Pairs {
+			hash.Pairs[cloneExpression(key)] = cloneExpression(value)
+		}
+		return hash
+	case *ImportExpression:
+		imp := &ImportExpression{Token: node.Token}
+		if node.Path != nil {
+			imp.Path = Clone(node.Path).(*StringLiteral)
+		}
+		return imp
+	}
+	panic("ast: Clone of unknown node " + node.String())
+}
+
+func cloneExpression(exp Expression) Expression {
+	if isNil(exp) {
+		return nil
+	}
+	return Clone(exp).(Expression)
+}
+
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return Clone(block).(*BlockStatement)
+}
+
+func cloneIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	copied := *ident
+	return &copied
+}
+
+func cloneStatements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+	cloned := make([]Statement, len(statements))
+	for i, s := range statements {
+		if !isNil(s) {
+			cloned[i] = Clone(s).(Statement)
+		}
+	}
+	return cloned
+}
+
+func cloneExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	cloned := make([]Expression, len(exps))
+	for i, exp := range exps {
+		cloned[i] = cloneExpression(exp)
+	}
+	return cloned
+}
+
+func cloneIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	cloned := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		cloned[i] = cloneIdentifier(ident)
+	}
+	return cloned
+}
@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+func TestClone(t *testing.T) {
+	nodes := []Node{
+		&Program{Statements: []Statement{
+			&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident("f"), Value: &FunctionLiteral{
+				Parameters: []*Identifier{ident("a")},
+				Body: &BlockStatement{Statements: []Statement{
+					&ReturnStatement{ReturnValue: &InfixExpression{Left: ident("a"), Operator: "+", Right: integer("1")}},
+				}},
+			}},
+			&ExportStatement{Statement: &LetStatement{Name: ident("x"), Value: &StringLiteral{Value: "s"}}},
+		}},
+		&IfExpression{
+			Condition:   &PrefixExpression{Operator: "!", Right: &Boolean{Value: true}},
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: integer("1")}}},
+		},
+		&CallExpression{Function: ident("f"), Arguments: []Expression{
+			&ArrayLiteral{Elements: []Expression{integer("1")}},
+			&IndexExpression{Left: ident("a"), Index: integer("0")},
+			&HashLiteral{Pairs: map[Expression]Expression{&StringLiteral{Value: "k"}: integer("2")}},
+			&MacroLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{}},
+			&ImportExpression{Path: &StringLiteral{Value: "m"}},
+		}},
+	}
+
+	for _, node := range nodes {
+		clone := Clone(node)
+		// hash literals are keyed by pointers, so compare the encodings rather than the nodes
+		want, _ := EncodeJSON(node)
+		got, _ := EncodeJSON(clone)
+		if string(got) != string(want) {
+			t.Errorf("clone differs:\n got=%s\nwant=%s", got, want)
+		}
+		// no node may be shared between the two trees
+		original := map[Node]bool{}
+		Inspect(node, func(n Node) bool {
+			original[n] = true
+			return true
+		})
+		Inspect(clone, func(n Node) bool {
+			if original[n] {
+				t.Errorf("%T %q is shared with the original", n, n.String())
+			}
+			return true
+		})
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	node := &InfixExpression{Left: integer("1"), Operator: "+", Right: integer("2")}
+	clone := Clone(node).(*InfixExpression)
+	clone.Left.(*IntegerLiteral).Value = 5
+	clone.Operator = "-"
+	if node.Left.(*IntegerLiteral).Value != 0 || node.Operator != "+" {
+		t.Errorf("modifying the clone changed the original: %#v", node)
+	}
+	if Clone(nil) != nil || Clone((*Identifier)(nil)) != nil {
+		t.Errorf("cloning nil should give nil")
+	}
+}
@@ -0,0 +1,747 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+// jsonNode is the JSON form of every kind of node. Kind is the name kinds gives the type
+// of the node. Pos is where the token held by the node starts (the operator of an infix
+// expression, the '(' of a call) and is left out for nodes that were not parsed. Nodes
+// only record where they start, so there is no end position. Only the fields of the kind
+// are present.
+type jsonNode struct {
+	Kind        string          `json:"kind"`
+	Pos         *jsonPos        `json:"pos,omitempty"`
+	Name        *jsonNode       `json:"name,omitempty"`
+	Pattern     *jsonNode       `json:"pattern,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"` // a node for let and return, else a JSON value
+	Operator    string          `json:"operator,omitempty"`
+	Left        *jsonNode       `json:"left,omitempty"`
+	Right       *jsonNode       `json:"right,omitempty"`
+	Expression  *jsonNode       `json:"expression,omitempty"`
+	Condition   *jsonNode       `json:"condition,omitempty"`
+	Consequence *jsonNode       `json:"consequence,omitempty"`
+	Alternative *jsonNode       `json:"alternative,omitempty"`
+	Parameters  []*jsonNode     `json:"parameters,omitempty"`
+	Patterns    []*jsonNode     `json:"patterns,omitempty"` // null for a plain parameter
+	Defaults    []*jsonNode     `json:"defaults,omitempty"` // null for a parameter without one
+	Rest        *jsonNode       `json:"rest,omitempty"`
+	Body        *jsonNode       `json:"body,omitempty"`
+	Function    *jsonNode       `json:"function,omitempty"`
+	Arguments   []*jsonNode     `json:"arguments,omitempty"`
+	Elements    []*jsonNode     `json:"elements,omitempty"`
+	Index       *jsonNode       `json:"index,omitempty"`
+	Pairs       []jsonPair      `json:"pairs,omitempty"`
+	Parts       []*jsonNode     `json:"parts,omitempty"`
+	Start       *jsonNode       `json:"start,omitempty"`
+	End         *jsonNode       `json:"end,omitempty"`
+	Step        *jsonNode       `json:"step,omitempty"`
+	Object      *jsonNode       `json:"object,omitempty"`
+	Property    *jsonNode       `json:"property,omitempty"`
+	Optional    bool            `json:"optional,omitempty"`
+	Subject     *jsonNode       `json:"subject,omitempty"`
+	Arms        []*jsonNode     `json:"arms,omitempty"`
+	Guard       *jsonNode       `json:"guard,omitempty"`
+	Keys        []*jsonNode     `json:"keys,omitempty"`
+	Values      []*jsonNode     `json:"values,omitempty"` // null where the key itself is bound
+	Path        *jsonNode       `json:"path,omitempty"`
+	Statement   *jsonNode       `json:"statement,omitempty"`
+	Statements  []*jsonNode     `json:"statements,omitempty"`
+}
+
+type jsonPos struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// jsonPair is a key and value of a hash literal, in the order of HashLiteral.Keys
+type jsonPair struct {
+	Key   *jsonNode `json:"key"`
+	Value *jsonNode `json:"value"`
+}
+
+// kinds names every type of node in the JSON form. decodeNode switches on the same names,
+// and a type missing here cannot be encoded.
+var kinds = map[reflect.Type]string{
+	reflect.TypeOf(&Program{}):             "Program",
+	reflect.TypeOf(&LetStatement{}):        "LetStatement",
+	reflect.TypeOf(&ExportStatement{}):     "ExportStatement",
+	reflect.TypeOf(&ReturnStatement{}):     "ReturnStatement",
+	reflect.TypeOf(&ExpressionStatement{}): "ExpressionStatement",
+	reflect.TypeOf(&BlockStatement{}):      "BlockStatement",
+	reflect.TypeOf(&Identifier{}):          "Identifier",
+	reflect.TypeOf(&IntegerLiteral{}):      "IntegerLiteral",
+	reflect.TypeOf(&FloatLiteral{}):        "FloatLiteral",
+	reflect.TypeOf(&StringLiteral{}):       "StringLiteral",
+	reflect.TypeOf(&InterpolatedString{}):  "InterpolatedString",
+	reflect.TypeOf(&Boolean{}):             "Boolean",
+	reflect.TypeOf(&NullLiteral{}):         "NullLiteral",
+	reflect.TypeOf(&PrefixExpression{}):    "PrefixExpression",
+	reflect.TypeOf(&InfixExpression{}):     "InfixExpression",
+	reflect.TypeOf(&IfExpression{}):        "IfExpression",
+	reflect.TypeOf(&FunctionLiteral{}):     "FunctionLiteral",
+	reflect.TypeOf(&MacroLiteral{}):        "MacroLiteral",
+	reflect.TypeOf(&CallExpression{}):      "CallExpression",
+	reflect.TypeOf(&ArrayLiteral{}):        "ArrayLiteral",
+	reflect.TypeOf(&IndexExpression{}):     "IndexExpression",
+	reflect.TypeOf(&SliceExpression{}):     "SliceExpression",
+	reflect.TypeOf(&MemberExpression{}):    "MemberExpression",
+	reflect.TypeOf(&HashLiteral{}):         "HashLiteral",
+	reflect.TypeOf(&ArrayPattern{}):        "ArrayPattern",
+	reflect.TypeOf(&HashPattern{}):         "HashPattern",
+	reflect.TypeOf(&MatchExpression{}):     "MatchExpression",
+	reflect.TypeOf(&MatchArm{}):            "MatchArm",
+	reflect.TypeOf(&SpreadExpression{}):    "SpreadExpression",
+	reflect.TypeOf(&ImportExpression{}):    "ImportExpression",
+}
+
+// Kinds returns the names of the kinds of node in the JSON form, sorted
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for _, name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EncodeJSON returns the JSON form of node and everything below it
+func EncodeJSON(node Node) ([]byte, error) {
+	var err error
+	Inspect(node, func(n Node) bool {
+		if _, ok := kinds[reflect.TypeOf(n)]; !ok && err == nil {
+			err = fmt.Errorf("ast: %T has no kind in the JSON form", n)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodeNode(node))
+}
+
+// DecodeJSON rebuilds a node from the output of EncodeJSON
+func DecodeJSON(data []byte) (Node, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return decodeNode(&n)
+}
+
+func encodePos(pos token.Position) *jsonPos {
+	if !pos.IsValid() {
+		return nil
+	}
+	return &jsonPos{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+}
+
+func encodeValue(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// encodeNodes encodes a list of expressions that may hold nils, leaving out a nil list
+func encodeNodes(exps []Expression) []*jsonNode {
+	if exps == nil {
+		return nil
+	}
+	nodes := make([]*jsonNode, len(exps))
+	for i, exp := range exps {
+		nodes[i] = encodeNode(exp)
+	}
+	return nodes
+}
+
+func encodeNode(node Node) *jsonNode {
+	if isNil(node) {
+		return nil
+	}
+	n := &jsonNode{Kind: kinds[reflect.TypeOf(node)]}
+	switch node := node.(type) {
+	case *Program:
+		n.Statements = make([]*jsonNode, len(node.Statements))
+		for i, s := range node.Statements {
+			n.Statements[i] = encodeNode(s)
+		}
+	case *LetStatement:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Name = encodeNode(node.Name)
+		n.Pattern = encodeNode(node.Pattern)
+		if value := encodeNode(node.Value); value != nil {
+			n.Value = encodeValue(value)
+		}
+	case *ExportStatement:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Statement = encodeNode(node.Statement)
+	case *ReturnStatement:
+		n.Pos = encodePos(node.Token.Pos)
+		if value := encodeNode(node.ReturnValue); value != nil {
+			n.Value = encodeValue(value)
+		}
+	case *ExpressionStatement:
+		n.Expression = encodeNode(node.Expression)
+	case *BlockStatement:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Statements = make([]*jsonNode, len(node.Statements))
+		for i, s := range node.Statements {
+			n.Statements[i] = encodeNode(s)
+		}
+	case *Identifier:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Value = encodeValue(node.Value)
+	case *IntegerLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Value = encodeValue(node.Value)
+	case *FloatLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Value = encodeValue(node.Value)
+	case *StringLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Value = encodeValue(node.Value)
+	case *InterpolatedString:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Parts = make([]*jsonNode, len(node.Parts))
+		for i, part := range node.Parts {
+			n.Parts[i] = encodeNode(part)
+		}
+	case *Boolean:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Value = encodeValue(node.Value)
+	case *NullLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+	case *PrefixExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Operator = node.Operator
+		n.Right = encodeNode(node.Right)
+	case *InfixExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Operator = node.Operator
+		n.Left = encodeNode(node.Left)
+		n.Right = encodeNode(node.Right)
+	case *IfExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Condition = encodeNode(node.Condition)
+		n.Consequence = encodeNode(node.Consequence)
+		n.Alternative = encodeNode(node.Alternative)
+	case *FunctionLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Parameters = make([]*jsonNode, len(node.Parameters))
+		for i, param := range node.Parameters {
+			n.Parameters[i] = encodeNode(param)
+		}
+		n.Patterns = encodeNodes(node.Patterns)
+		if node.Defaults != nil {
+			n.Defaults = make([]*jsonNode, len(node.Defaults))
+			for i, def := range node.Defaults {
+				n.Defaults[i] = encodeNode(def)
+			}
+		}
+		n.Rest = encodeNode(node.Rest)
+		n.Body = encodeNode(node.Body)
+	case *SpreadExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Expression = encodeNode(node.Value)
+	case *ArrayPattern:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Elements = encodeNodes(node.Elements)
+		n.Rest = encodeNode(node.Rest)
+	case *MatchExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Subject = encodeNode(node.Subject)
+		n.Arms = make([]*jsonNode, len(node.Arms))
+		for i, arm := range node.Arms {
+			n.Arms[i] = encodeNode(arm)
+		}
+	case *MatchArm:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Pattern = encodeNode(node.Pattern)
+		n.Guard = encodeNode(node.Guard)
+		n.Body = encodeNode(node.Body)
+	case *HashPattern:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Keys = make([]*jsonNode, len(node.Keys))
+		for i, key := range node.Keys {
+			n.Keys[i] = encodeNode(key)
+		}
+		n.Values = encodeNodes(node.Values)
+	case *MacroLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Parameters = make([]*jsonNode, len(node.Parameters))
+		for i, param := range node.Parameters {
+			n.Parameters[i] = encodeNode(param)
+		}
+		n.Rest = encodeNode(node.Rest)
+		n.Body = encodeNode(node.Body)
+	case *CallExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Function = encodeNode(node.Function)
+		n.Optional = node.Optional
+		n.Arguments = make([]*jsonNode, len(node.Arguments))
+		for i, arg := range node.Arguments {
+			n.Arguments[i] = encodeNode(arg)
+		}
+	case *ArrayLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Elements = make([]*jsonNode, len(node.Elements))
+		for i, el := range node.Elements {
+			n.Elements[i] = encodeNode(el)
+		}
+	case *IndexExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Left = encodeNode(node.Left)
+		n.Index = encodeNode(node.Index)
+		n.Optional = node.Optional
+	case *SliceExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Left = encodeNode(node.Left)
+		n.Start = encodeNode(node.Start)
+		n.End = encodeNode(node.End)
+		n.Step = encodeNode(node.Step)
+		n.Optional = node.Optional
+	case *MemberExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Object = encodeNode(node.Object)
+		n.Property = encodeNode(node.Property)
+		n.Optional = node.Optional
+	case *HashLiteral:
+		n.Pos = encodePos(node.Token.Pos)
+		for _, key := range node.Keys() {
+			n.Pairs = append(n.Pairs, jsonPair{Key: encodeNode(key), Value: encodeNode(node.Pairs[key])})
+		}
+	case *ImportExpression:
+		n.Pos = encodePos(node.Token.Pos)
+		n.Path = encodeNode(node.Path)
+	}
+	return n
+}
+
+// tok rebuilds the token a node of the given kind holds
+func tok(typ token.TokenType, literal string, pos *jsonPos) token.Token {
+	t := token.Token{Type: typ, Literal: literal}
+	if pos != nil {
+		t.Pos = token.Position{Offset: pos.Offset, Line: pos.Line, Column: pos.Column}
+	}
+	return t
+}
+
+func decodeExpression(n *jsonNode, field string) (Expression, error) {
+	if n == nil {
+		return nil, nil
+	}
+	node, err := decodeNode(n)
+	if err != nil {
+		return nil, err
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		return nil, fmt.Errorf("ast: %s cannot be used as %s", n.Kind, field)
+	}
+	return exp, nil
+}
+
+func decodeStatement(n *jsonNode, field string) (Statement, error) {
+	node, err := decodeNode(n)
+	if err != nil {
+		return nil, err
+	}
+	stmt, ok := node.(Statement)
+	if !ok {
+		return nil, fmt.Errorf("ast: %s cannot be used as %s", n.Kind, field)
+	}
+	return stmt, nil
+}
+
+func decodeBlock(n *jsonNode, field string) (*BlockStatement, error) {
+	if n == nil {
+		return nil, nil
+	}
+	node, err := decodeNode(n)
+	if err != nil {
+		return nil, err
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		return nil, fmt.Errorf("ast: %s cannot be used as %s", n.Kind, field)
+	}
+	return block, nil
+}
+
+func decodeIdentifier(n *jsonNode, field string) (*Identifier, error) {
+	node, err := decodeNode(n)
+	if err != nil {
+		return nil, err
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		return nil, fmt.Errorf("ast: %s cannot be used as %s", n.Kind, field)
+	}
+	return ident, nil
+}
+
+// decodePattern decodes an identifier, array pattern or hash pattern
+func decodePattern(n *jsonNode, field string) (Expression, error) {
+	exp, err := decodeExpression(n, field)
+	if err != nil {
+		return nil, err
+	}
+	switch exp.(type) {
+	case *Identifier, *ArrayPattern, *HashPattern:
+		return exp, nil
+	}
+	return nil, fmt.Errorf("ast: %s cannot be used as %s", n.Kind, field)
+}
+
+// decodePatterns decodes a list of patterns, keeping nulls as nil
+func decodePatterns(list []*jsonNode, field string) ([]Expression, error) {
+	patterns := make([]Expression, len(list))
+	for i, n := range list {
+		if n == nil {
+			continue
+		}
+		pattern, err := decodePattern(n, field)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = pattern
+	}
+	return patterns, nil
+}
+
+func decodeIdentifiers(list []*jsonNode, field string) ([]*Identifier, error) {
+	idents := make([]*Identifier, len(list))
+	for i, n := range list {
+		ident, err := decodeIdentifier(n, field)
+		if err != nil {
+			return nil, err
+		}
+		idents[i] = ident
+	}
+	return idents, nil
+}
+
+func decodeExpressions(list []*jsonNode, field string) ([]Expression, error) {
+	exps := make([]Expression, len(list))
+	for i, n := range list {
+		exp, err := decodeExpression(n, field)
+		if err != nil {
+			return nil, err
+		}
+		exps[i] = exp
+	}
+	return exps, nil
+}
+
+func decodeStatements(list []*jsonNode, field string) ([]Statement, error) {
+	stmts := make([]Statement, len(list))
+	for i, n := range list {
+		stmt, err := decodeStatement(n, field)
+		if err != nil {
+			return nil, err
+		}
+		stmts[i] = stmt
+	}
+	return stmts, nil
+}
+
+// decodeChild decodes the node held in the value of a let or return
+func decodeChild(value json.RawMessage, field string) (Expression, error) {
+	if len(value) == 0 || string(value) == "null" {
+		return nil, nil
+	}
+	var n jsonNode
+	if err := json.Unmarshal(value, &n); err != nil {
+		return nil, err
+	}
+	return decodeExpression(&n, field)
+}
+
+func decodeNode(n *jsonNode) (Node, error) {
+	if n == nil {
+		return nil, fmt.Errorf("ast: missing node")
+	}
+	var err error
+	switch n.Kind {
+	case "Program":
+		program := &Program{}
+		program.Statements, err = decodeStatements(n.Statements, "a statement of a program")
+		return program, err
+	case "LetStatement":
+		let := &LetStatement{Token: tok(token.LET, "let", n.Pos)}
+		if n.Pattern != nil {
+			if let.Pattern, err = decodePattern(n.Pattern, "the pattern of a let"); err != nil {
+				return nil, err
+			}
+		} else if let.Name, err = decodeIdentifier(n.Name, "the name of a let"); err != nil {
+			return nil, err
+		}
+		let.Value, err = decodeChild(n.Value, "the value of a let")
+		return let, err
+	case "ExportStatement":
+		export := &ExportStatement{Token: tok(token.EXPORT, "export", n.Pos)}
+		stmt, err := decodeStatement(n.Statement, "an exported statement")
+		if err != nil {
+			return nil, err
+		}
+		let, ok := stmt.(*LetStatement)
+		if !ok {
+			return nil, fmt.Errorf("ast: %s cannot be exported", n.Statement.Kind)
+		}
+		export.Statement = let
+		return export, nil
+	case "ReturnStatement":
+		ret := &ReturnStatement{Token: tok(token.RETURN, "return", n.Pos)}
+		ret.ReturnValue, err = decodeChild(n.Value, "a return value")
+		return ret, err
+	case "ExpressionStatement":
+		stmt := &ExpressionStatement{}
+		if stmt.Expression, err = decodeExpression(n.Expression, "an expression statement"); err != nil {
+			return nil, err
+		}
+		if stmt.Expression != nil {
+			stmt.Token = token.Token{Literal: stmt.Expression.TokenLiteral(), Pos: stmt.Expression.Pos()}
+		}
+		return stmt, nil
+	case "BlockStatement":
+		block := &BlockStatement{Token: tok(token.LBRACE, "{", n.Pos)}
+		block.Statements, err = decodeStatements(n.Statements, "a statement of a block")
+		return block, err
+	case "Identifier":
+		ident := &Identifier{}
+		if err := json.Unmarshal(n.Value, &ident.Value); err != nil {
+			return nil, fmt.Errorf("ast: identifier: %s", err)
+		}
+		ident.Token = tok(token.IDENT, ident.Value, n.Pos)
+		return ident, nil
+	case "IntegerLiteral":
+		// parsed from the text so that large values keep their precision
+		value, err := strconv.ParseInt(string(n.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ast: integer literal: %s", err)
+		}
+		return &IntegerLiteral{Token: tok(token.INT, string(n.Value), n.Pos), Value: value}, nil
+	case "FloatLiteral":
+		value, err := strconv.ParseFloat(string(n.Value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("ast: float literal: %s", err)
+		}
+		return &FloatLiteral{Token: tok(token.FLOAT, FormatFloat(value), n.Pos), Value: value}, nil
+	case "StringLiteral":
+		str := &StringLiteral{}
+		if err := json.Unmarshal(n.Value, &str.Value); err != nil {
+			return nil, fmt.Errorf("ast: string literal: %s", err)
+		}
+		str.Token = tok(token.STRING, str.Value, n.Pos)
+		return str, nil
+	case "InterpolatedString":
+		template := &InterpolatedString{}
+		if template.Parts, err = decodeExpressions(n.Parts, "a part of a template string"); err != nil {
+			return nil, err
+		}
+		template.Token = tok(token.TEMPLATE, template.String(), n.Pos)
+		return template, nil
+	case "Boolean":
+		b := &Boolean{}
+		if err := json.Unmarshal(n.Value, &b.Value); err != nil {
+			return nil, fmt.Errorf("ast: boolean: %s", err)
+		}
+		b.Token = tok(token.FALSE, "false", n.Pos)
+		if b.Value {
+			b.Token = tok(token.TRUE, "true", n.Pos)
+		}
+		return b, nil
+	case "NullLiteral":
+		return &NullLiteral{Token: tok(token.NULL, "null", n.Pos)}, nil
+	case "PrefixExpression":
+		prefix := &PrefixExpression{Token: tok(token.TokenType(n.Operator), n.Operator, n.Pos), Operator: n.Operator}
+		prefix.Right, err = decodeExpression(n.Right, "the operand of "+n.Operator)
+		return prefix, err
+	case "InfixExpression":
+		infix := &InfixExpression{Token: tok(token.TokenType(n.Operator), n.Operator, n.Pos), Operator: n.Operator}
+		if infix.Left, err = decodeExpression(n.Left, "the operand of "+n.Operator); err != nil {
+			return nil, err
+		}
+		infix.Right, err = decodeExpression(n.Right, "the operand of "+n.Operator)
+		return infix, err
+	case "IfExpression":
+		ifExp := &IfExpression{Token: tok(token.IF, "if", n.Pos)}
+		if ifExp.Condition, err = decodeExpression(n.Condition, "a condition"); err != nil {
+			return nil, err
+		}
+		if ifExp.Consequence, err = decodeBlock(n.Consequence, "the consequence of an if"); err != nil {
+			return nil, err
+		}
+		ifExp.Alternative, err = decodeBlock(n.Alternative, "the alternative of an if")
+		return ifExp, err
+	case "FunctionLiteral":
+		fn := &FunctionLiteral{Token: tok(token.FUNCTION, "fn", n.Pos)}
+		if n.Patterns != nil {
+			if fn.Patterns, err = decodePatterns(n.Patterns, "a parameter pattern"); err != nil {
+				return nil, err
+			}
+		}
+		fn.Parameters = make([]*Identifier, len(n.Parameters))
+		for i, param := range n.Parameters {
+			if param == nil && fn.Pattern(i) != nil {
+				continue
+			}
+			if fn.Parameters[i], err = decodeIdentifier(param, "a parameter"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Defaults != nil {
+			if fn.Defaults, err = decodeExpressions(n.Defaults, "a default value"); err != nil {
+				return nil, err
+			}
+		}
+		if n.Rest != nil {
+			if fn.Rest, err = decodeIdentifier(n.Rest, "a rest parameter"); err != nil {
+				return nil, err
+			}
+		}
+		fn.Body, err = decodeBlock(n.Body, "the body of a function")
+		return fn, err
+	case "MacroLiteral":
+		macro := &MacroLiteral{Token: tok(token.MACRO, "macro", n.Pos)}
+		if macro.Parameters, err = decodeIdentifiers(n.Parameters, "a parameter"); err != nil {
+			return nil, err
+		}
+		if n.Rest != nil {
+			if macro.Rest, err = decodeIdentifier(n.Rest, "a rest parameter"); err != nil {
+				return nil, err
+			}
+		}
+		macro.Body, err = decodeBlock(n.Body, "the body of a macro")
+		return macro, err
+	case "CallExpression":
+		call := &CallExpression{Token: tok(token.LPAREN, "(", n.Pos), Optional: n.Optional}
+		if call.Function, err = decodeExpression(n.Function, "a function"); err != nil {
+			return nil, err
+		}
+		call.Arguments, err = decodeExpressions(n.Arguments, "an argument")
+		return call, err
+	case "ArrayPattern":
+		pattern := &ArrayPattern{Token: tok(token.LBRACKET, "[", n.Pos)}
+		if pattern.Elements, err = decodePatterns(n.Elements, "an element of an array pattern"); err != nil {
+			return nil, err
+		}
+		for _, el := range pattern.Elements {
+			if el == nil {
+				return nil, fmt.Errorf("ast: missing node")
+			}
+		}
+		if n.Rest != nil {
+			pattern.Rest, err = decodeIdentifier(n.Rest, "a rest element")
+		}
+		return pattern, err
+	case "MatchExpression":
+		match := &MatchExpression{Token: tok(token.MATCH, "match", n.Pos)}
+		if match.Subject, err = decodeExpression(n.Subject, "the subject of a match"); err != nil {
+			return nil, err
+		}
+		match.Arms = make([]*MatchArm, len(n.Arms))
+		for i, armNode := range n.Arms {
+			node, err := decodeNode(armNode)
+			if err != nil {
+				return nil, err
+			}
+			arm, ok := node.(*MatchArm)
+			if !ok {
+				return nil, fmt.Errorf("ast: %s cannot be used as an arm of a match", armNode.Kind)
+			}
+			match.Arms[i] = arm
+		}
+		return match, nil
+	case "MatchArm":
+		arm := &MatchArm{Token: tok(token.ARROW, "=>", n.Pos)}
+		if arm.Pattern, err = decodeExpression(n.Pattern, "a match pattern"); err != nil {
+			return nil, err
+		}
+		if arm.Guard, err = decodeExpression(n.Guard, "a guard"); err != nil {
+			return nil, err
+		}
+		arm.Body, err = decodeExpression(n.Body, "the body of a match arm")
+		return arm, err
+	case "HashPattern":
+		pattern := &HashPattern{Token: tok(token.LBRACE, "{", n.Pos)}
+		if pattern.Keys, err = decodeIdentifiers(n.Keys, "a key of a hash pattern"); err != nil {
+			return nil, err
+		}
+		if n.Values != nil {
+			pattern.Values, err = decodePatterns(n.Values, "a value of a hash pattern")
+		}
+		return pattern, err
+	case "SpreadExpression":
+		spread := &SpreadExpression{Token: tok(token.ELLIPSIS, "...", n.Pos)}
+		spread.Value, err = decodeExpression(n.Expression, "a spread value")
+		return spread, err
+	case "ArrayLiteral":
+		array := &ArrayLiteral{Token: tok(token.LBRACKET, "[", n.Pos)}
+		array.Elements, err = decodeExpressions(n.Elements, "an element")
+		return array, err
+	case "IndexExpression":
+		index := &IndexExpression{Token: tok(token.LBRACKET, "[", n.Pos), Optional: n.Optional}
+		if index.Left, err = decodeExpression(n.Left, "an indexed value"); err != nil {
+			return nil, err
+		}
+		index.Index, err = decodeExpression(n.Index, "an index")
+		return index, err
+	case "SliceExpression":
+		slice := &SliceExpression{Token: tok(token.LBRACKET, "[", n.Pos), Optional: n.Optional}
+		if slice.Left, err = decodeExpression(n.Left, "a sliced value"); err != nil {
+			return nil, err
+		}
+		if slice.Start, err = decodeExpression(n.Start, "the start of a slice"); err != nil {
+			return nil, err
+		}
+		if slice.End, err = decodeExpression(n.End, "the end of a slice"); err != nil {
+			return nil, err
+		}
+		slice.Step, err = decodeExpression(n.Step, "the step of a slice")
+		return slice, err
+	case "MemberExpression":
+		member := &MemberExpression{Token: tok(token.DOT, ".", n.Pos), Optional: n.Optional}
+		if member.Optional {
+			member.Token = tok(token.OPTIONAL, "?.", n.Pos)
+		}
+		if member.Object, err = decodeExpression(n.Object, "an object"); err != nil {
+			return nil, err
+		}
+		member.Property, err = decodeIdentifier(n.Property, "a property")
+		return member, err
+	case "HashLiteral":
+		hash := &HashLiteral{Token: tok(token.LBRACE, "{", n.Pos), Pairs: make(map[Expression]Expression)}
+		for _, pair := range n.Pairs {
+			key, err := decodeExpression(pair.Key, "a key")
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeExpression(pair.Value, "a value")
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs[key] = value
+		}
+		return hash, nil
+	case "ImportExpression":
+		imp := &ImportExpression{Token: tok(token.IMPORT, "import", n.Pos)}
+		path, err := decodeExpression(n.Path, "an import path")
+		if err != nil {
+			return nil, err
+		}
+		str, ok := path.(*StringLiteral)
+		if !ok {
+			return nil, fmt.Errorf("ast: %s cannot be used as an import path", n.Path.Kind)
+		}
+		imp.Path = str
+		return imp, nil
+	}
+	return nil, fmt.Errorf("ast: unknown node kind %q", n.Kind)
+}
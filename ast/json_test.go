@@ -0,0 +1,47 @@
+package ast
+
+import "testing"
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind":"Loop"}`, `ast: unknown node kind "Loop"`},
+		{`{"kind":"Program","statements":[{"kind":"Identifier","value":"x"}]}`,
+			"ast: Identifier cannot be used as a statement of a program"},
+		{`{"kind":"CallExpression","function":{"kind":"Identifier","value":"f"},"arguments":[{"kind":"ReturnStatement"}]}`,
+			"ast: ReturnStatement cannot be used as an argument"},
+		{`{"kind":"FunctionLiteral","parameters":[{"kind":"IntegerLiteral","value":1}]}`,
+			"ast: IntegerLiteral cannot be used as a parameter"},
+		{`{"kind":"IntegerLiteral","value":"one"}`, `ast: integer literal: strconv.ParseInt: parsing "\"one\"": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestDecodeJSONWithoutPositions(t *testing.T) {
+	// nodes built by tools need not carry positions
+	node, err := DecodeJSON([]byte(`{"kind":"PrefixExpression","operator":"-","right":{"kind":"IntegerLiteral","value":5}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.String() != "(-5)" || node.Pos().IsValid() {
+		t.Errorf("wrong node %s at %s", node, node.Pos())
+	}
+}
+
+// loop stands in for a kind of node that the JSON form does not know about yet
+type loop struct{ Identifier }
+
+func TestEncodeJSONUnknownKind(t *testing.T) {
+	node := &PrefixExpression{Operator: "-", Right: &loop{}}
+	if _, err := EncodeJSON(node); err == nil || err.Error() != "ast: *ast.loop has no kind in the JSON form" {
+		t.Errorf("expected an error for an unknown kind, got %v", err)
+	}
+}
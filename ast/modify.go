@@ -1,55 +1,258 @@
 package ast
 
+import "fmt"
+
 type ModifierFunc func(Node) Node // if the modifier cannot handle the node, it should return it
 
-func Modify(node Node, modifier ModifierFunc) Node {
+// MismatchError reports a node returned by a modifier that cannot take the place of the
+// node it replaced, e.g. a let statement as the argument of a call
+type MismatchError struct {
+	Parent Node
+	Field  string // the field of Parent holding the child
+	Got    Node   // nil if the modifier returned nil
+}
+
+func (e *MismatchError) Error() string {
+	if e.Got == nil {
+		return fmt.Sprintf("%T.%s: modifier returned nil", e.Parent, e.Field)
+	}
+	return fmt.Sprintf("%T.%s: cannot use %T %q", e.Parent, e.Field, e.Got, e.Got.String())
+}
+
+// Modify calls modifier for every node below node, children first, replacing each node with
+// what the modifier returns, and finally returns modifier(node). A replacement that does not
+// fit where it is used is converted when there is an obvious conversion, such as wrapping an
+// expression in an expression statement; otherwise the original child is kept and the first
+// such mismatch is returned.
+func Modify(node Node, modifier ModifierFunc) (Node, error) {
+	m := &modification{modifier: modifier}
+	result := m.modify(node)
+	return result, m.err
+}
+
+type modification struct {
+	modifier ModifierFunc
+	err      error
+}
+
+func (m *modification) modify(node Node) Node {
 	switch node := node.(type) {
 	case *Program:
 		for i, statement := range node.Statements {
-			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+			node.Statements[i] = m.statement(node, "Statements", statement)
 		}
 	case *ExpressionStatement:
-		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+		node.Expression = m.expression(node, "Expression", node.Expression)
 	case *InfixExpression:
-		node.Left, _ = Modify(node.Left, modifier).(Expression)
-		node.Right, _ = Modify(node.Right, modifier).(Expression)
+		node.Left = m.expression(node, "Left", node.Left)
+		node.Right = m.expression(node, "Right", node.Right)
 	case *PrefixExpression:
-		node.Right, _ = Modify(node.Right, modifier).(Expression)
+		node.Right = m.expression(node, "Right", node.Right)
 	case *IndexExpression:
-		node.Left, _ = Modify(node.Left, modifier).(Expression)
-		node.Index, _ = Modify(node.Index, modifier).(Expression)
-	case *IfExpression:
-		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
-		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
-		if node.Alternative != nil {
-			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		node.Left = m.expression(node, "Left", node.Left)
+		node.Index = m.expression(node, "Index", node.Index)
+	case *InterpolatedString:
+		for i, part := range node.Parts {
+			node.Parts[i] = m.expression(node, "Parts", part)
 		}
+	case *SliceExpression:
+		node.Left = m.expression(node, "Left", node.Left)
+		node.Start = m.expression(node, "Start", node.Start)
+		node.End = m.expression(node, "End", node.End)
+		node.Step = m.expression(node, "Step", node.Step)
+	case *MemberExpression:
+		node.Object = m.expression(node, "Object", node.Object)
+		node.Property = m.identifier(node, "Property", node.Property)
+	case *IfExpression:
+		node.Condition = m.expression(node, "Condition", node.Condition)
+		node.Consequence = m.block(node, "Consequence", node.Consequence)
+		node.Alternative = m.block(node, "Alternative", node.Alternative)
 	case *BlockStatement:
-		for i := range node.Statements {
-			node.Statements[i], _ = Modify(node.Statements[i], modifier).(Statement)
+		for i, statement := range node.Statements {
+			node.Statements[i] = m.statement(node, "Statements", statement)
 		}
 	case *ReturnStatement:
-		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+		node.ReturnValue = m.expression(node, "ReturnValue", node.ReturnValue)
 	case *LetStatement:
-		node.Value, _ = Modify(node.Value, modifier).(Expression)
+		node.Name = m.identifier(node, "Name", node.Name)
+		node.Pattern = m.expression(node, "Pattern", node.Pattern)
+		node.Value = m.expression(node, "Value", node.Value)
+	case *ExportStatement:
+		if !isNil(node.Statement) {
+			result := m.modify(node.Statement)
+			if let, ok := result.(*LetStatement); ok && let != nil {
+				node.Statement = let
+			} else {
+				m.mismatch(node, "Statement", result)
+			}
+		}
 	case *FunctionLiteral:
-		for i := range node.Parameters {
-			node.Parameters[i], _ = Modify(node.Parameters[i], modifier).(*Identifier)
+		for i, param := range node.Parameters {
+			node.Parameters[i] = m.identifier(node, "Parameters", param)
+		}
+		for i, pattern := range node.Patterns {
+			node.Patterns[i] = m.expression(node, "Patterns", pattern)
+		}
+		for i, def := range node.Defaults {
+			node.Defaults[i] = m.expression(node, "Defaults", def)
+		}
+		node.Rest = m.identifier(node, "Rest", node.Rest)
+		node.Body = m.block(node, "Body", node.Body)
+	case *SpreadExpression:
+		node.Value = m.expression(node, "Value", node.Value)
+	case *ArrayPattern:
+		for i, el := range node.Elements {
+			node.Elements[i] = m.expression(node, "Elements", el)
+		}
+		node.Rest = m.identifier(node, "Rest", node.Rest)
+	case *MatchExpression:
+		node.Subject = m.expression(node, "Subject", node.Subject)
+		for i, arm := range node.Arms {
+			if isNil(arm) {
+				continue
+			}
+			result := m.modify(arm)
+			if modified, ok := result.(*MatchArm); ok && modified != nil {
+				node.Arms[i] = modified
+			} else {
+				m.mismatch(node, "Arms", result)
+			}
+		}
+	case *MatchArm:
+		node.Pattern = m.expression(node, "Pattern", node.Pattern)
+		node.Guard = m.expression(node, "Guard", node.Guard)
+		node.Body = m.expression(node, "Body", node.Body)
+	case *HashPattern:
+		for i, key := range node.Keys {
+			node.Keys[i] = m.identifier(node, "Keys", key)
+		}
+		for i, value := range node.Values {
+			node.Values[i] = m.expression(node, "Values", value)
+		}
+	case *MacroLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i] = m.identifier(node, "Parameters", param)
+		}
+		node.Rest = m.identifier(node, "Rest", node.Rest)
+		node.Body = m.block(node, "Body", node.Body)
+	case *CallExpression:
+		node.Function = m.expression(node, "Function", node.Function)
+		for i, arg := range node.Arguments {
+			node.Arguments[i] = m.expression(node, "Arguments", arg)
 		}
-		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 	case *ArrayLiteral:
-		for i := range node.Elements {
-			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
+		for i, el := range node.Elements {
+			node.Elements[i] = m.expression(node, "Elements", el)
 		}
 	case *HashLiteral:
 		newPairs := make(map[Expression]Expression)
-		for key, val := range node.Pairs {
-			newKey, _ := Modify(key, modifier).(Expression)
-			newVal, _ := Modify(val, modifier).(Expression)
-			newPairs[newKey] = newVal
+		for _, key := range node.Keys() {
+			val := node.Pairs[key]
+			newPairs[m.expression(node, "Pairs", key)] = m.expression(node, "Pairs", val)
 		}
 		node.Pairs = newPairs
+	case *ImportExpression:
+		if !isNil(node.Path) {
+			result := m.modify(node.Path)
+			if path, ok := result.(*StringLiteral); ok && path != nil {
+				node.Path = path
+			} else {
+				m.mismatch(node, "Path", result)
+			}
+		}
+	}
+
+	return m.modifier(node)
+}
+
+func (m *modification) mismatch(parent Node, field string, got Node) {
+	if m.err == nil {
+		if isNil(got) {
+			got = nil
+		}
+		m.err = &MismatchError{Parent: parent, Field: field, Got: got}
+	}
+}
+
+// expression modifies an expression child, unwrapping an expression statement returned for it
+func (m *modification) expression(parent Node, field string, exp Expression) Expression {
+	if isNil(exp) {
+		return exp
+	}
+	switch result := m.modify(exp).(type) {
+	case Expression:
+		if !isNil(result) {
+			return result
+		}
+	case *ExpressionStatement:
+		if !isNil(result.Expression) {
+			return result.Expression
+		}
+	case Node:
+		m.mismatch(parent, field, result)
+		return exp
+	}
+	m.mismatch(parent, field, nil)
+	return exp
+}
+
+// statement modifies a statement child, wrapping an expression returned for it
+func (m *modification) statement(parent Node, field string, stmt Statement) Statement {
+	if isNil(stmt) {
+		return stmt
+	}
+	switch result := m.modify(stmt).(type) {
+	case Statement:
+		if !isNil(result) {
+			return result
+		}
+	case Expression:
+		if !isNil(result) {
+			return &ExpressionStatement{Expression: result}
+		}
+	case Node:
+		m.mismatch(parent, field, result)
+		return stmt
 	}
+	m.mismatch(parent, field, nil)
+	return stmt
+}
 
-	return modifier(node)
+// block modifies a block child, wrapping a statement or expression returned for it
+func (m *modification) block(parent Node, field string, block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	switch result := m.modify(block).(type) {
+	case *BlockStatement:
+		if result != nil {
+			return result
+		}
+	case Statement:
+		if !isNil(result) {
+			return &BlockStatement{Token: block.Token, Statements: []Statement{result}}
+		}
+	case Expression:
+		if !isNil(result) {
+			return &BlockStatement{Token: block.Token, Statements: []Statement{&ExpressionStatement{Expression: result}}}
+		}
+	case Node:
+		m.mismatch(parent, field, result)
+		return block
+	}
+	m.mismatch(parent, field, nil)
+	return block
+}
+
+// identifier modifies an identifier child, which can only be replaced by another identifier
+func (m *modification) identifier(parent Node, field string, ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	result := m.modify(ident)
+	if replaced, ok := result.(*Identifier); ok && replaced != nil {
+		return replaced
+	}
+	m.mismatch(parent, field, result)
+	return ident
 }
@@ -115,10 +115,35 @@ func TestModify(t *testing.T) {
 			&ArrayLiteral{Elements: []Expression{one(), one()}},
 			&ArrayLiteral{Elements: []Expression{two(), two()}},
 		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), two()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
-		modified := Modify(tt.input, turnOneIntoTwo) // this will call our modifier function for every child of the node
+		modified, err := Modify(tt.input, turnOneIntoTwo) // this will call our modifier function for every child of the node
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
 		equal := reflect.DeepEqual(modified, tt.expected)
 		if !equal {
 			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
@@ -146,3 +171,64 @@ func TestModify(t *testing.T) {
 	}
 
 }
+
+func TestModifyConversions(t *testing.T) {
+	// a let statement replacing an integer: wrapped in a block, rejected as an argument
+	oneToLet := func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+			return &LetStatement{Name: &Identifier{Value: "x"}, Value: &IntegerLiteral{Value: 2}}
+		}
+		return node
+	}
+	// an expression statement replacing a block
+	blockToStatement := func(node Node) Node {
+		if block, ok := node.(*BlockStatement); ok && len(block.Statements) == 1 {
+			return block.Statements[0]
+		}
+		return node
+	}
+
+	call := &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{&IntegerLiteral{Value: 1}}}
+	_, err := Modify(call, oneToLet)
+	mismatch, ok := err.(*MismatchError)
+	if !ok || mismatch.Parent != call || mismatch.Field != "Arguments" {
+		t.Fatalf("expected a mismatch in the arguments, got %v", err)
+	}
+	if _, ok := call.Arguments[0].(*IntegerLiteral); !ok {
+		t.Errorf("the argument should have been kept, got %#v", call.Arguments[0])
+	}
+
+	program := &Program{Statements: []Statement{&ExpressionStatement{Expression: &IntegerLiteral{Value: 1}}}}
+	if _, err := Modify(program, func(node Node) Node {
+		if stmt, ok := node.(*ExpressionStatement); ok {
+			return stmt.Expression
+		}
+		return node
+	}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := program.Statements[0].(*ExpressionStatement); !ok {
+		t.Errorf("the expression should have been wrapped in a statement, got %#v", program.Statements[0])
+	}
+
+	ifExp := &IfExpression{
+		Condition:   &Boolean{Value: true},
+		Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &IntegerLiteral{Value: 3}}}},
+	}
+	if _, err := Modify(ifExp, blockToStatement); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ifExp.Consequence == nil || len(ifExp.Consequence.Statements) != 1 {
+		t.Errorf("the statement should have been wrapped in a block, got %#v", ifExp.Consequence)
+	}
+
+	_, err = Modify(&ReturnStatement{ReturnValue: &IntegerLiteral{Value: 1}}, func(node Node) Node {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return nil
+		}
+		return node
+	})
+	if err == nil || err.Error() != "*ast.ReturnStatement.ReturnValue: modifier returned nil" {
+		t.Errorf("expected a nil mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package ast
+
+import "reflect"
+
+// Visitor is called for every node reached by Walk
+type Visitor interface {
+	// Pre is called before the children of node, which are skipped if it returns false
+	Pre(node Node) bool
+	// Post is called after the children of node, or straight after Pre if they were skipped
+	Post(node Node)
+}
+
+// Walk traverses node depth-first, visiting the children of each node in source order.
+// Nil children, including nil pointers left behind by a partial parse, are not visited.
+func Walk(v Visitor, node Node) {
+	if isNil(node) {
+		return
+	}
+	if v.Pre(node) {
+		for _, child := range Children(node) {
+			Walk(v, child)
+		}
+	}
+	v.Post(node)
+}
+
+// inspector visits in pre-order only
+type inspector func(Node) bool
+
+func (f inspector) Pre(node Node) bool { return f(node) }
+func (f inspector) Post(Node)          {}
+
+// Inspect calls f for node and its descendants in pre-order, skipping the children of
+// any node for which f returns false
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// Children returns the direct children of node in source order, leaving out nil ones
+func Children(node Node) []Node {
+	var children []Node
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			if !isNil(n) {
+				children = append(children, n)
+			}
+		}
+	}
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			add(s)
+		}
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			add(s)
+		}
+	case *LetStatement:
+		add(node.Name, node.Pattern, node.Value)
+	case *ExportStatement:
+		add(node.Statement)
+	case *ReturnStatement:
+		add(node.ReturnValue)
+	case *ExpressionStatement:
+		add(node.Expression)
+	case *PrefixExpression:
+		add(node.Right)
+	case *InfixExpression:
+		add(node.Left, node.Right)
+	case *IfExpression:
+		add(node.Condition, node.Consequence, node.Alternative)
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			add(param, node.Pattern(i), node.Default(i))
+		}
+		add(node.Rest, node.Body)
+	case *SpreadExpression:
+		add(node.Value)
+	case *ArrayPattern:
+		for _, el := range node.Elements {
+			add(el)
+		}
+		add(node.Rest)
+	case *HashPattern:
+		for i, key := range node.Keys {
+			add(key, node.Value(i))
+		}
+	case *MatchExpression:
+		add(node.Subject)
+		for _, arm := range node.Arms {
+			add(arm)
+		}
+	case *MatchArm:
+		add(node.Pattern, node.Guard, node.Body)
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			add(param)
+		}
+		add(node.Rest, node.Body)
+	case *CallExpression:
+		add(node.Function)
+		for _, arg := range node.Arguments {
+			add(arg)
+		}
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			add(el)
+		}
+	case *IndexExpression:
+		add(node.Left, node.Index)
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			add(part)
+		}
+	case *SliceExpression:
+		add(node.Left, node.Start, node.End, node.Step)
+	case *MemberExpression:
+		add(node.Object, node.Property)
+	case *HashLiteral:
+		for _, key := range node.Keys() {
+			add(key, node.Pairs[key])
+		}
+	case *ImportExpression:
+		add(node.Path)
+	}
+	return children
+}
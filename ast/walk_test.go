@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(literal string) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal}}
+}
+
+// recorder writes the token literal of each node as it is entered and left
+type recorder struct {
+	sb   strings.Builder
+	skip string // do not descend into nodes with this literal
+}
+
+func (r *recorder) Pre(node Node) bool {
+	r.sb.WriteString("<" + node.TokenLiteral())
+	return node.TokenLiteral() != r.skip
+}
+
+func (r *recorder) Post(node Node) {
+	r.sb.WriteString(">")
+}
+
+func TestWalk(t *testing.T) {
+	// let f = fn(a) { g(a, 1) }
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("f"),
+			Value: &FunctionLiteral{
+				Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Parameters: []*Identifier{ident("a")},
+				Body: &BlockStatement{
+					Token: token.Token{Type: token.LBRACE, Literal: "{"},
+					Statements: []Statement{&ExpressionStatement{
+						Token: token.Token{Type: token.IDENT, Literal: "g"},
+						Expression: &CallExpression{
+							Token:     token.Token{Type: token.LPAREN, Literal: "("},
+							Function:  ident("g"),
+							Arguments: []Expression{ident("a"), integer("1")},
+						},
+					}},
+				},
+			},
+		},
+	}}
+
+	tests := []struct {
+		skip     string
+		expected string
+	}{
+		{"", "<let<f><fn<a><{<g<(<g><a><1>>>>>>"},
+		{"fn", "<let<f><fn>>"},
+		{"(", "<let<f><fn<a><{<g<(>>>>>"},
+	}
+	for _, tt := range tests {
+		r := &recorder{skip: tt.skip}
+		Walk(r, program)
+		// the program itself has the literal of its first statement
+		if got := strings.TrimPrefix(r.sb.String(), "<let"); got != tt.expected+">" {
+			t.Errorf("skipping %q: expected %s, got %s", tt.skip, tt.expected+">", got)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	// a partial parse can leave nil children, which are not visited
+	call := &CallExpression{Token: token.Token{Literal: "("}, Function: ident("f"), Arguments: []Expression{
+		&InfixExpression{Token: token.Token{Literal: "+"}, Left: integer("1"), Operator: "+", Right: (*IntegerLiteral)(nil)},
+		&HashLiteral{Token: token.Token{Literal: "{"}, Pairs: map[Expression]Expression{
+			&StringLiteral{Token: token.Token{Literal: "b"}, Value: "b"}: integer("2"),
+			&StringLiteral{Token: token.Token{Literal: "a"}, Value: "a"}: integer("3"),
+		}},
+		&IfExpression{Token: token.Token{Literal: "if"}, Condition: ident("c"), Consequence: &BlockStatement{}},
+	}}
+	var visited []string
+	Inspect(call, func(node Node) bool {
+		visited = append(visited, node.TokenLiteral())
+		_, isIf := node.(*IfExpression)
+		return !isIf
+	})
+	// hash keys that were not parsed are visited alphabetically
+	if got := strings.Join(visited, " "); got != "( f + 1 { a 3 b 2 if" {
+		t.Errorf("wrong order %q", got)
+	}
+}
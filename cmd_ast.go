@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/format"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+const astUsage = `usage: monkey ast [-decode] [file]
+
+Prints the syntax tree of Monkey source as JSON. With -decode it reads
+JSON instead and prints the source it describes. Without a file it reads stdin.
+`
+
+// astCommand implements `monkey ast`. It exits with 2 when the input cannot be read,
+// parsed or decoded.
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), astUsage)
+		flags.PrintDefaults()
+	}
+	decode := flags.Bool("decode", false, "read JSON and print Monkey source")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name := "<stdin>"
+	var input []byte
+	var err error
+	if flags.NArg() == 0 {
+		input, err = io.ReadAll(os.Stdin)
+	} else {
+		name = flags.Arg(0)
+		input, err = os.ReadFile(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *decode {
+		node, err := ast.DecodeJSON(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+			return 2
+		}
+		fmt.Println(format.Node(node))
+		return 0
+	}
+
+	p := parser.New(lexer.New(string(input)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, err)
+		}
+		return 2
+	}
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Println(string(data))
+	return 0
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/format"
+)
+
+const fmtUsage = `usage: monkey fmt [-check | -diff | -write] [path ...]
+
+Formats Monkey source. Without paths it reads stdin and writes stdout.
+Directories are searched for *%s files.
+`
+
+// fmtCommand implements `monkey fmt`. It exits with 1 when -check or -diff finds
+// unformatted files and with 2 when a file cannot be read or parsed.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), fmtUsage, evaluator.ModuleExtension)
+		flags.PrintDefaults()
+	}
+	check := flags.Bool("check", false, "list files whose formatting differs and exit with 1 if there are any")
+	diff := flags.Bool("diff", false, "print a diff of the changes instead of the formatted source")
+	write := flags.Bool("write", false, "rewrite files in place")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %s\n", err)
+			return 2
+		}
+		switch {
+		case *check:
+			if !bytes.Equal(src, formatted) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+		case *diff:
+			fmt.Print(format.Diff("<stdin>", "<stdin>", src, formatted))
+			if !bytes.Equal(src, formatted) {
+				return 1
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// only look at Monkey files inside directories, but always format files named explicitly
+			if d.IsDir() || (file != path && filepath.Ext(file) != evaluator.ModuleExtension) {
+				return nil
+			}
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			formatted, err := format.Source(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+				status = 2
+				return nil
+			}
+			changed := !bytes.Equal(src, formatted)
+			switch {
+			case *check:
+				if changed {
+					fmt.Println(file)
+				}
+			case *diff:
+				fmt.Print(format.Diff(file+".orig", file, src, formatted))
+			case *write:
+				if changed {
+					// the file exists, so WriteFile keeps its mode and the 0644 is unused
+					return os.WriteFile(file, formatted, 0644)
+				}
+			default:
+				os.Stdout.Write(formatted)
+			}
+			if changed && (*check || *diff) && status == 0 {
+				status = 1
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+		}
+	}
+	return status
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+	"github.com/josh-weston/go_interpreter/vet"
+)
+
+const vetUsage = `usage: monkey vet [-config file] [-json] [-rules] [path ...]
+
+Reports suspicious code in Monkey source. Without paths it reads stdin.
+Directories are searched for *%s files. Rules are turned on and off by
+the config file, %s in the working directory if -config is not given.
+`
+
+// vetCommand implements `monkey vet`. It exits with 1 when there are diagnostics and with
+// 2 when the config or a file cannot be read or parsed.
+func vetCommand(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), vetUsage, evaluator.ModuleExtension, vet.ConfigFile)
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "read the enabled rules from `file`")
+	asJSON := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	listRules := flags.Bool("rules", false, "list the rules and whether they are enabled")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var config *vet.Config
+	path := *configPath
+	if path == "" {
+		if _, err := os.Stat(vet.ConfigFile); err == nil {
+			path = vet.ConfigFile
+		}
+	}
+	if path != "" {
+		var err error
+		if config, err = vet.LoadConfig(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	if *listRules {
+		for _, rule := range vet.Rules {
+			state := "off"
+			if config.Enabled(rule) {
+				state = "on"
+			}
+			fmt.Printf("%-20s %-4s %s\n", rule.Name, state, rule.Doc)
+		}
+		return 0
+	}
+
+	status := 0
+	diagnostics := []vet.Diagnostic{}
+	check := func(file string, src []byte) {
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if errs := p.ErrorList(); len(errs) != 0 {
+			for _, err := range errs {
+				fmt.Fprintf(os.Stderr, "%s:%s: %s\n", file, err.Pos, err.Msg)
+			}
+			status = 2
+			return
+		}
+		diagnostics = append(diagnostics, vet.Check(file, program, string(src), config)...)
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		check("<stdin>", src)
+	}
+	for _, path := range flags.Args() {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// only look at Monkey files inside directories, but always check files named explicitly
+			if d.IsDir() || (file != path && filepath.Ext(file) != evaluator.ModuleExtension) {
+				return nil
+			}
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			check(file, src)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+		}
+	}
+
+	if *asJSON {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		out.Encode(diagnostics)
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+	}
+	if len(diagnostics) != 0 && status == 0 {
+		status = 1
+	}
+	return status
+}
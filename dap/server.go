@@ -0,0 +1,463 @@
+// Package dap implements a Debug Adapter Protocol server over stdio, so editors can run
+// Monkey programs under the debugger with breakpoints, stepping and variable inspection.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/debugger"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/object"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+// the program runs on a single thread as far as the client is concerned
+const threadID = 1
+
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type Server struct {
+	in *bufio.Reader
+
+	mu      sync.Mutex // guards out, seq and stopped, events are written from another goroutine
+	out     io.Writer
+	seq     int
+	stopped bool
+
+	path     string // the program being debugged
+	program  *ast.Program
+	debugger *debugger.Debugger
+	started  bool
+	exited   chan struct{}
+
+	// variable references handed out since the program last stopped
+	refs map[int]*object.Environment
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, refs: make(map[int]*object.Environment)}
+}
+
+// Serve handles requests from in until the client disconnects or in is closed
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Run()
+}
+
+func (s *Server) Run() error {
+	// puts must not write into the protocol stream
+	evaluator.Output = outputWriter{s}
+	defer func() { evaluator.Output = os.Stdout }()
+
+	for {
+		body, err := s.readMessage()
+		if err == io.EOF {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		result, err := s.handle(&req)
+		if err != nil {
+			s.write(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: err.Error()})
+			continue
+		}
+		s.write(&response{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: result})
+		s.after(req.Command)
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// readMessage reads one message framed by a Content-Length header
+func (s *Server) readMessage() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("malformed header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:colon]), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", line[colon+1:])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// write numbers and sends a response or event
+func (s *Server) write(msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *Server) send(name string, body interface{}) {
+	s.write(&event{Type: "event", Event: name, Body: body})
+}
+
+// outputWriter turns program output into output events
+type outputWriter struct{ s *Server }
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.send("output", map[string]interface{}{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
+
+func (s *Server) handle(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		return nil, s.launch(req.Arguments)
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		return nil, s.start()
+	case "threads":
+		return map[string]interface{}{"threads": []map[string]interface{}{{"id": threadID, "name": "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, s.checkStopped()
+	case "next", "stepIn", "stepOut":
+		return nil, s.checkStopped()
+	case "pause":
+		if s.debugger == nil {
+			return nil, errors.New("no program is running")
+		}
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %s", req.Command)
+}
+
+// after does the work that must follow a response, so that the client sees the response
+// to a stepping command before the program stops again
+func (s *Server) after(command string) {
+	switch command {
+	case "initialize":
+		s.send("initialized", nil)
+	case "continue":
+		s.resume((*debugger.Debugger).Continue)
+	case "next":
+		s.resume((*debugger.Debugger).StepOver)
+	case "stepIn":
+		s.resume((*debugger.Debugger).StepIn)
+	case "stepOut":
+		s.resume((*debugger.Debugger).StepOut)
+	}
+}
+
+func (s *Server) launch(args json.RawMessage) error {
+	var params struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return err
+	}
+	src, err := os.ReadFile(params.Program)
+	if err != nil {
+		return err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) != 0 {
+		return fmt.Errorf("%s:%s", params.Program, errs[0])
+	}
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, macroErrs := evaluator.ExpandMacros(program, macroEnv)
+	if len(macroErrs) != 0 {
+		return fmt.Errorf("%s:%s", params.Program, macroErrs[0])
+	}
+	s.program = expanded
+	s.path = params.Program
+	s.debugger = debugger.New()
+	s.debugger.StopOnEntry = params.StopOnEntry
+	return nil
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	if s.debugger == nil {
+		return nil, errors.New("launch the program before setting breakpoints")
+	}
+	var params struct {
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	lines := []int{}
+	verified := []map[string]interface{}{}
+	for _, bp := range params.Breakpoints {
+		lines = append(lines, bp.Line)
+		verified = append(verified, map[string]interface{}{"verified": true, "line": bp.Line})
+	}
+	s.debugger.SetBreakpoints(lines)
+	return map[string]interface{}{"breakpoints": verified}, nil
+}
+
+// start runs the program once the client has sent its configuration
+func (s *Server) start() error {
+	if s.debugger == nil {
+		return errors.New("no program has been launched")
+	}
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.exited = make(chan struct{})
+	s.debugger.Run(s.program, object.NewEnvironment())
+	go s.forward()
+	return nil
+}
+
+// forward relays debugger events to the client until the program exits
+func (s *Server) forward() {
+	defer close(s.exited)
+	for ev := range s.debugger.Events() {
+		switch ev := ev.(type) {
+		case *debugger.Stopped:
+			s.mu.Lock()
+			s.stopped = true
+			s.mu.Unlock()
+			s.send("stopped", map[string]interface{}{
+				"reason":            ev.Reason,
+				"threadId":          threadID,
+				"allThreadsStopped": true,
+			})
+		case *debugger.Exited:
+			code := 0
+			if err, ok := ev.Result.(*object.Error); ok {
+				if err != debugger.ErrTerminated {
+					s.send("output", map[string]interface{}{"category": "stderr", "output": err.Inspect() + "\n"})
+				}
+				code = 1
+			}
+			s.send("exited", map[string]interface{}{"exitCode": code})
+			s.send("terminated", nil)
+			return
+		}
+	}
+}
+
+func (s *Server) checkStopped() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return errors.New("the program is not stopped")
+	}
+	return nil
+}
+
+// resume passes a stepping command to the stopped program
+func (s *Server) resume(step func(*debugger.Debugger)) {
+	s.mu.Lock()
+	s.stopped = false
+	s.mu.Unlock()
+	s.refs = make(map[int]*object.Environment)
+	step(s.debugger)
+}
+
+func (s *Server) terminate() {
+	if !s.started {
+		return
+	}
+	s.debugger.Terminate()
+	<-s.exited
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	if err := s.checkStopped(); err != nil {
+		return nil, err
+	}
+	frames := []map[string]interface{}{}
+	for i, f := range s.debugger.Stack() {
+		frames = append(frames, map[string]interface{}{
+			"id":     i + 1,
+			"name":   f.Name,
+			"line":   f.Pos.Line,
+			"column": f.Pos.Column,
+			"source": map[string]interface{}{"path": s.path},
+		})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *Server) frame(args json.RawMessage) (*debugger.Frame, error) {
+	var params struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	stack := s.debugger.Stack()
+	if params.FrameID < 1 || params.FrameID > len(stack) {
+		return nil, fmt.Errorf("unknown frame %d", params.FrameID)
+	}
+	return stack[params.FrameID-1], nil
+}
+
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	if err := s.checkStopped(); err != nil {
+		return nil, err
+	}
+	f, err := s.frame(args)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []map[string]interface{}{}
+	chain := debugger.Scopes(f.Env)
+	for i, scope := range chain {
+		name := "Closure"
+		switch {
+		case i == len(chain)-1:
+			name = "Globals"
+		case i == 0:
+			name = "Locals"
+		}
+		ref := len(s.refs) + 1
+		s.refs[ref] = scope.Env
+		scopes = append(scopes, map[string]interface{}{
+			"name":               name,
+			"variablesReference": ref,
+			"expensive":          false,
+		})
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var params struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	env, ok := s.refs[params.VariablesReference]
+	if !ok {
+		return nil, fmt.Errorf("unknown variables reference %d", params.VariablesReference)
+	}
+	variables := []map[string]interface{}{}
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		variables = append(variables, map[string]interface{}{
+			"name":               name,
+			"value":              value.Inspect(),
+			"type":               string(value.Type()),
+			"variablesReference": 0,
+		})
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (s *Server) evaluate(args json.RawMessage) (interface{}, error) {
+	if err := s.checkStopped(); err != nil {
+		return nil, err
+	}
+	var params struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	frame := 0
+	if params.FrameID > 0 {
+		frame = params.FrameID - 1
+	}
+	result := s.debugger.Evaluate(params.Expression, frame)
+	if err, ok := result.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	value := "null"
+	if result != nil {
+		value = result.Inspect()
+	}
+	return map[string]interface{}{"result": value, "variablesReference": 0}, nil
+}
@@ -0,0 +1,291 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testProgram = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+puts(x);
+x
+`
+
+type message struct {
+	Seq     int             `json:"seq"`
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// client talks to a server running on its own goroutine
+type client struct {
+	t        *testing.T
+	w        *io.PipeWriter
+	messages chan message
+	done     chan error
+	seq      int
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, messages: make(chan message, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- Serve(inR, outW)
+		outW.Close()
+	}()
+	go func() {
+		r := textproto.NewReader(bufio.NewReader(outR))
+		for {
+			header, err := r.ReadMIMEHeader()
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			length, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r.R, body); err != nil {
+				close(c.messages)
+				return
+			}
+			var msg message
+			json.Unmarshal(body, &msg)
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) request(command string, args interface{}) {
+	c.seq++
+	body, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// wait checks that the server shut down cleanly
+func (c *client) wait() {
+	c.t.Helper()
+	if err := <-c.done; err != nil {
+		c.t.Errorf("Serve returned %v", err)
+	}
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return message{}
+}
+
+// response waits for the response to command, decoding its body into v
+func (c *client) response(command string, v interface{}) {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Type != "response" || msg.Command != command {
+		c.t.Fatalf("expected the response to %s, got %+v", command, msg)
+	}
+	if !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+	if v != nil {
+		if err := json.Unmarshal(msg.Body, v); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+// event waits for the event name, decoding its body into v
+func (c *client) event(name string, v interface{}) {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Type != "event" || msg.Event != name {
+		c.t.Fatalf("expected the %s event, got %+v", name, msg)
+	}
+	if v != nil {
+		if err := json.Unmarshal(msg.Body, v); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+	var body struct{ Reason string }
+	c.event("stopped", &body)
+	if body.Reason != reason {
+		c.t.Fatalf("expected to stop for %s, stopped for %s", reason, body.Reason)
+	}
+}
+
+func (c *client) line() int {
+	c.t.Helper()
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+	var body struct {
+		StackFrames []struct{ Line int }
+	}
+	c.response("stackTrace", &body)
+	return body.StackFrames[0].Line
+}
+
+func launch(t *testing.T, breakpoints ...int) *client {
+	path := filepath.Join(t.TempDir(), "main.monkey")
+	if err := os.WriteFile(path, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", map[string]interface{}{"adapterID": "monkey"})
+	c.response("initialize", nil)
+	c.event("initialized", nil)
+	c.request("launch", map[string]interface{}{"program": path})
+	c.response("launch", nil)
+
+	lines := []map[string]interface{}{}
+	for _, line := range breakpoints {
+		lines = append(lines, map[string]interface{}{"line": line})
+	}
+	c.request("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": lines})
+	var body struct {
+		Breakpoints []struct {
+			Verified bool
+			Line     int
+		}
+	}
+	c.response("setBreakpoints", &body)
+	if len(body.Breakpoints) != len(breakpoints) {
+		t.Fatalf("expected %d breakpoints, got %+v", len(breakpoints), body.Breakpoints)
+	}
+	c.request("configurationDone", nil)
+	c.response("configurationDone", nil)
+	return c
+}
+
+func TestBreakpointAndInspection(t *testing.T) {
+	c := launch(t, 3)
+	c.stopped("breakpoint")
+
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+	var trace struct {
+		StackFrames []struct {
+			ID   int
+			Name string
+			Line int
+		}
+	}
+	c.response("stackTrace", &trace)
+	if len(trace.StackFrames) != 2 || trace.StackFrames[0].Name != "add" || trace.StackFrames[0].Line != 3 ||
+		trace.StackFrames[1].Name != "main" || trace.StackFrames[1].Line != 5 {
+		t.Fatalf("wrong stack %+v", trace.StackFrames)
+	}
+
+	c.request("scopes", map[string]interface{}{"frameId": trace.StackFrames[0].ID})
+	var scopes struct {
+		Scopes []struct {
+			Name               string
+			VariablesReference int
+		}
+	}
+	c.response("scopes", &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes %+v", scopes.Scopes)
+	}
+
+	c.request("variables", map[string]interface{}{"variablesReference": scopes.Scopes[0].VariablesReference})
+	var vars struct {
+		Variables []struct{ Name, Value, Type string }
+	}
+	c.response("variables", &vars)
+	got := map[string]string{}
+	for _, v := range vars.Variables {
+		got[v.Name] = v.Value
+	}
+	if len(got) != 3 || got["a"] != "1" || got["b"] != "2" || got["sum"] != "3" {
+		t.Errorf("wrong locals %+v", vars.Variables)
+	}
+
+	c.request("evaluate", map[string]interface{}{"expression": "a * 10 + b", "frameId": trace.StackFrames[0].ID})
+	var eval struct{ Result string }
+	c.response("evaluate", &eval)
+	if eval.Result != "12" {
+		t.Errorf("wrong evaluate result %q", eval.Result)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": threadID})
+	c.response("continue", nil)
+	var output struct{ Category, Output string }
+	c.event("output", &output)
+	if output.Category != "stdout" || output.Output != "3\n" {
+		t.Errorf("wrong output %+v", output)
+	}
+	var exited struct{ ExitCode int }
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code %d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	c.request("disconnect", nil)
+	c.response("disconnect", nil)
+	c.wait()
+}
+
+func TestStepping(t *testing.T) {
+	c := launch(t, 5)
+	c.stopped("breakpoint")
+
+	steps := []struct {
+		command string
+		line    int
+	}{
+		{"stepIn", 2},
+		{"next", 3},
+		{"stepOut", 6},
+	}
+	for _, step := range steps {
+		c.request(step.command, map[string]interface{}{"threadId": threadID})
+		c.response(step.command, nil)
+		c.stopped("step")
+		if line := c.line(); line != step.line {
+			t.Fatalf("%s: expected line %d, got %d", step.command, step.line, line)
+		}
+	}
+
+	c.request("disconnect", nil)
+	c.event("exited", nil)
+	c.event("terminated", nil)
+	c.response("disconnect", nil)
+	c.wait()
+}
+
+func TestRequestsNeedStoppedProgram(t *testing.T) {
+	c := newClient(t)
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+	msg := c.next()
+	if msg.Type != "response" || msg.Success {
+		t.Errorf("expected stackTrace to fail without a program, got %+v", msg)
+	}
+	c.request("disconnect", nil)
+	c.response("disconnect", nil)
+	c.wait()
+}
@@ -0,0 +1,304 @@
+// Package debugger runs a program under the evaluator's debug hook so it can be paused at
+// breakpoints, stepped through and inspected. Front-ends such as the REPL's :debug mode and
+// the DAP server drive it from their own goroutine while the program runs on another.
+package debugger
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/object"
+	"github.com/josh-weston/go_interpreter/parser"
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+// Frame is one entry of the call stack
+type Frame struct {
+	Name string
+	Env  *object.Environment // the environment of the statement being run, nil before the first one
+	Pos  token.Position      // the statement being run, or the call site until then
+}
+
+// Event is sent by the debugger when the program stops or finishes
+type Event interface{ event() }
+
+// reasons a program can stop
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+type Stopped struct {
+	Reason string
+	Pos    token.Position
+}
+
+type Exited struct {
+	Result object.Object
+}
+
+func (*Stopped) event() {}
+func (*Exited) event()  {}
+
+type stepMode int
+
+const (
+	stepNone stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// command is sent to a stopped program to resume it, or to evaluate an expression in it
+type command struct {
+	step  stepMode
+	eval  string
+	frame int
+	reply chan object.Object
+}
+
+// Debugger controls a single program. Breakpoints are set by line and apply to every
+// statement starting on that line.
+type Debugger struct {
+	StopOnEntry bool
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pause       bool // stop at the next statement
+
+	quit     chan struct{} // closed to abort the program
+	quitOnce sync.Once
+
+	// owned by the goroutine running the program, and safe to read while it is stopped
+	stack     []*Frame
+	step      stepMode
+	stepDepth int
+	stopLine  int
+	stopFrame *Frame
+
+	events chan Event
+	resume chan command
+}
+
+func New() *Debugger {
+	return &Debugger{
+		breakpoints: make(map[int]bool),
+		events:      make(chan Event),
+		resume:      make(chan command),
+		quit:        make(chan struct{}),
+	}
+}
+
+// Events delivers a Stopped event each time the program pauses and a final Exited event.
+// The program blocks until each event is received, so it must be drained until Exited.
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+// Run starts evaluating program in env on a new goroutine
+func (d *Debugger) Run(program *ast.Program, env *object.Environment) {
+	d.stack = []*Frame{{Name: "main", Env: env, Pos: program.Pos()}}
+	if d.StopOnEntry {
+		d.pause = true
+	}
+	evaluator.DebugHook = d
+	go func() {
+		result := evaluator.Eval(program, env)
+		evaluator.DebugHook = nil
+		d.events <- &Exited{Result: result}
+	}()
+}
+
+// SetBreakpoints replaces the breakpoints with the given lines
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = make(map[int]bool)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Continue resumes a stopped program until the next breakpoint
+func (d *Debugger) Continue() { d.resume <- command{step: stepNone} }
+
+// StepIn resumes a stopped program until the next statement, entering calls
+func (d *Debugger) StepIn() { d.resume <- command{step: stepIn} }
+
+// StepOver resumes a stopped program until the next statement of the current function
+func (d *Debugger) StepOver() { d.resume <- command{step: stepOver} }
+
+// StepOut resumes a stopped program until the current function returns
+func (d *Debugger) StepOut() { d.resume <- command{step: stepOut} }
+
+// Pause asks a running program to stop at its next statement
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// Terminate aborts the program, which still reports an Exited event. It may be called
+// whether the program is stopped or running.
+func (d *Debugger) Terminate() {
+	d.quitOnce.Do(func() { close(d.quit) })
+}
+
+// Stack returns the call stack of a stopped program, innermost frame first
+func (d *Debugger) Stack() []*Frame {
+	frames := make([]*Frame, len(d.stack))
+	for i, f := range d.stack {
+		frames[len(d.stack)-1-i] = f
+	}
+	return frames
+}
+
+// Evaluate parses src and evaluates it in the environment of frame, counted from the
+// innermost, of a stopped program. Like the stepping commands it blocks until the
+// program is stopped.
+func (d *Debugger) Evaluate(src string, frame int) object.Object {
+	reply := make(chan object.Object)
+	d.resume <- command{eval: src, frame: frame, reply: reply}
+	return <-reply
+}
+
+// Statement implements evaluator.Hook
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	top := d.stack[len(d.stack)-1]
+	top.Env = env
+	top.Pos = stmt.Pos()
+
+	select {
+	case <-d.quit:
+		return ErrTerminated
+	default:
+	}
+	d.mu.Lock()
+	reason := d.stopReason(top)
+	d.mu.Unlock()
+	if reason == "" {
+		return nil
+	}
+
+	d.stopLine, d.stopFrame = top.Pos.Line, top
+	d.events <- &Stopped{Reason: reason, Pos: top.Pos}
+	for {
+		select {
+		case <-d.quit:
+			return ErrTerminated
+		case cmd := <-d.resume:
+			if cmd.reply != nil {
+				cmd.reply <- d.evaluate(cmd.eval, cmd.frame)
+				continue
+			}
+			d.step, d.stepDepth = cmd.step, len(d.stack)
+			return nil
+		}
+	}
+}
+
+// ErrTerminated is the result of a program aborted by Terminate
+var ErrTerminated = &object.Error{Message: "terminated by debugger"}
+
+// stopReason decides whether to stop before a statement of frame top
+func (d *Debugger) stopReason(top *Frame) string {
+	line := top.Pos.Line
+	// several statements can share a line, only the first of them stops
+	sameLine := line == d.stopLine && top == d.stopFrame
+	switch {
+	case d.pause:
+		d.pause = false
+		if d.stopFrame == nil {
+			return ReasonEntry
+		}
+		return ReasonPause
+	case d.step == stepIn && !sameLine:
+		return ReasonStep
+	case d.step == stepOver && len(d.stack) <= d.stepDepth && !sameLine:
+		return ReasonStep
+	case d.step == stepOut && len(d.stack) < d.stepDepth:
+		return ReasonStep
+	case d.breakpoints[line] && !sameLine:
+		return ReasonBreakpoint
+	}
+	return ""
+}
+
+// Call implements evaluator.Hook
+func (d *Debugger) Call(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	d.stack = append(d.stack, &Frame{Name: callName(call), Pos: call.Pos()})
+}
+
+// Return implements evaluator.Hook
+func (d *Debugger) Return(call *ast.CallExpression, result object.Object) {
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+func callName(call *ast.CallExpression) string {
+	switch fn := call.Function.(type) {
+	case *ast.Identifier:
+		return fn.Value
+	case *ast.FunctionLiteral:
+		return "fn"
+	default:
+		return fn.String()
+	}
+}
+
+// evaluate runs src while the program is stopped, with the hook disabled so that it
+// cannot stop again
+func (d *Debugger) evaluate(src string, frame int) object.Object {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return &object.Error{Message: errs[0]}
+	}
+	stack := d.Stack()
+	if frame < 0 || frame >= len(stack) || stack[frame].Env == nil {
+		return &object.Error{Message: "no such frame"}
+	}
+	evaluator.DebugHook = nil
+	defer func() { evaluator.DebugHook = d }()
+	return evaluator.Eval(program, stack[frame].Env)
+}
+
+// Scope is one environment in the chain visible from a frame
+type Scope struct {
+	Env       *object.Environment
+	Variables []Variable
+}
+
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Scopes lists the environments visible from env, innermost first
+func Scopes(env *object.Environment) []Scope {
+	var scopes []Scope
+	for ; env != nil; env = env.Outer() {
+		scope := Scope{Env: env}
+		for _, name := range env.Names() {
+			value, _ := env.Get(name)
+			scope.Variables = append(scope.Variables, Variable{Name: name, Value: value})
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
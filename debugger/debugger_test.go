@@ -0,0 +1,141 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/object"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+const testProgram = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 3);
+y * 2
+`
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// stop waits for the next event and checks that the program stopped at line
+func stop(t *testing.T, d *Debugger, reason string, line int) {
+	t.Helper()
+	switch ev := (<-d.Events()).(type) {
+	case *Stopped:
+		if ev.Reason != reason || ev.Pos.Line != line {
+			t.Fatalf("expected to stop at line %d (%s), stopped at line %d (%s)", line, reason, ev.Pos.Line, ev.Reason)
+		}
+	case *Exited:
+		t.Fatalf("expected to stop at line %d, program exited with %v", line, ev.Result)
+	}
+}
+
+func exit(t *testing.T, d *Debugger) object.Object {
+	t.Helper()
+	ev, ok := (<-d.Events()).(*Exited)
+	if !ok {
+		t.Fatalf("expected the program to exit, got %#v", ev)
+	}
+	return ev.Result
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New()
+	d.SetBreakpoints([]int{2, 6})
+	d.Run(parse(t, testProgram), object.NewEnvironment())
+
+	stop(t, d, ReasonBreakpoint, 2) // first call of add
+	d.Continue()
+	stop(t, d, ReasonBreakpoint, 6)
+	d.Continue()
+	stop(t, d, ReasonBreakpoint, 2) // second call of add
+	d.Continue()
+	result := exit(t, d)
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 12 {
+		t.Errorf("wrong result %v", result)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d := New()
+	d.StopOnEntry = true
+	d.Run(parse(t, testProgram), object.NewEnvironment())
+
+	stop(t, d, ReasonEntry, 1)
+	d.StepOver()
+	stop(t, d, ReasonStep, 5)
+	d.StepIn()
+	stop(t, d, ReasonStep, 2)
+	d.StepOver()
+	stop(t, d, ReasonStep, 3)
+	d.StepOut()
+	stop(t, d, ReasonStep, 6)
+	d.StepOver()
+	stop(t, d, ReasonStep, 7)
+	d.Continue()
+	exit(t, d)
+}
+
+func TestStackAndScopes(t *testing.T) {
+	d := New()
+	d.SetBreakpoints([]int{3})
+	d.Run(parse(t, testProgram), object.NewEnvironment())
+	stop(t, d, ReasonBreakpoint, 3)
+
+	stack := d.Stack()
+	if len(stack) != 2 || stack[0].Name != "add" || stack[1].Name != "main" {
+		t.Fatalf("wrong stack %+v", stack)
+	}
+	if stack[0].Pos.Line != 3 || stack[1].Pos.Line != 5 {
+		t.Errorf("wrong frame positions %s, %s", stack[0].Pos, stack[1].Pos)
+	}
+
+	scopes := Scopes(stack[0].Env)
+	if len(scopes) != 2 {
+		t.Fatalf("expected the locals and globals of add, got %d scopes", len(scopes))
+	}
+	locals := map[string]string{}
+	for _, v := range scopes[0].Variables {
+		locals[v.Name] = v.Value.Inspect()
+	}
+	if locals["a"] != "1" || locals["b"] != "2" || locals["sum"] != "3" {
+		t.Errorf("wrong locals %v", locals)
+	}
+	if len(scopes[1].Variables) != 1 || scopes[1].Variables[0].Name != "add" {
+		t.Errorf("wrong globals %+v", scopes[1].Variables)
+	}
+
+	if got := d.Evaluate("sum * 10", 0).Inspect(); got != "30" {
+		t.Errorf("evaluating in add gave %s", got)
+	}
+	if got := d.Evaluate("sum", 1).Inspect(); got != "ERROR: identifier not found: sum" {
+		t.Errorf("evaluating in main gave %s", got)
+	}
+
+	d.SetBreakpoints(nil)
+	d.Continue()
+	exit(t, d)
+}
+
+func TestTerminate(t *testing.T) {
+	d := New()
+	d.SetBreakpoints([]int{5})
+	d.Run(parse(t, testProgram), object.NewEnvironment())
+	stop(t, d, ReasonBreakpoint, 5)
+	d.Terminate()
+	result := exit(t, d)
+	if err, ok := result.(*object.Error); !ok || err != ErrTerminated {
+		t.Errorf("expected the program to be terminated, got %v", result)
+	}
+}
@@ -2,19 +2,28 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
 
 	"github.com/josh-weston/go_interpreter/object"
 )
 
+// Output is where puts writes, a debugger can redirect it away from its own protocol stream
+var Output io.Writer = os.Stdout
+
 var builtins = map[string]*object.Builtin{
 	"len": {
+		Signature: "len(value)",
+		Doc:       "Returns the number of characters in a STRING or elements in an ARRAY.",
+		Params:    []object.BuiltinParam{{Name: "value", Types: []object.ObjectType{object.STRING_OBJ, object.ARRAY_OBJ}}},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
@@ -23,6 +32,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": {
+		Signature: "first(array)",
+		Doc:       "Returns the first element of an ARRAY, or NULL if it is empty.",
+		Params:    []object.BuiltinParam{{Name: "array", Types: []object.ObjectType{object.ARRAY_OBJ}}},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
@@ -38,9 +50,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": {
+		Signature: "last(array)",
+		Doc:       "Returns the last element of an ARRAY, or NULL if it is empty.",
+		Params:    []object.BuiltinParam{{Name: "array", Types: []object.ObjectType{object.ARRAY_OBJ}}},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong umber of arguments. got=%d, want=1", len(args))
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to `last` must be Array, got %s", args[0].Type())
@@ -54,6 +69,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": {
+		Signature: "rest(array)",
+		Doc:       "Returns a new ARRAY holding every element but the first, or NULL if it is empty.",
+		Params:    []object.BuiltinParam{{Name: "array", Types: []object.ObjectType{object.ARRAY_OBJ}}},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
@@ -72,6 +90,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": {
+		Signature: "push(array, value)",
+		Doc:       "Returns a new ARRAY with value added to the end.",
+		Params: []object.BuiltinParam{
+			{Name: "array", Types: []object.ObjectType{object.ARRAY_OBJ}},
+			{Name: "value"},
+		},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
@@ -83,15 +107,35 @@ var builtins = map[string]*object.Builtin{
 			length := len(arr.Elements)
 			newElements := make([]object.Object, length+1)
 			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
 			return &object.Array{Elements: newElements}
 		},
 	},
 	"puts": {
+		Signature: "puts(values...)",
+		Doc:       "Prints each value on its own line and returns NULL.",
+		Params:    []object.BuiltinParam{{Name: "values"}},
+		Variadic:  true,
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(Output, arg.Inspect())
 			}
 			return NULL
 		},
 	},
 }
+
+// BuiltinNames returns the names of all builtin functions
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	return names
+}
+
+// LookupBuiltin returns the builtin function called name
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
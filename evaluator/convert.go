@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// evalInterpolatedString joins the text of a template string with its expressions
+// converted by str()
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var sb strings.Builder
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		if value == nil {
+			value = NULL // the value of an empty block
+		}
+		sb.WriteString(object.Str(value))
+	}
+	return &object.String{Value: sb.String()}
+}
+
+// numberTypes are the types int() and float() convert
+var numberTypes = []object.ObjectType{object.INTEGER_OBJ, object.FLOAT_OBJ, object.STRING_OBJ, object.BOOLEAN_OBJ}
+
+// conversion returns a builtin taking one value of the given types, any type if there are none
+func conversion(name, doc string, types []object.ObjectType, convert func(object.Object) object.Object) *object.Builtin {
+	return &object.Builtin{
+		Signature: name + "(value)",
+		Doc:       doc,
+		Params:    []object.BuiltinParam{{Name: "value", Types: types}},
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return convert(args[0])
+		},
+	}
+}
+
+func init() {
+	builtins["type"] = conversion("type", "Returns the name of the type of value, e.g. \"INTEGER\".", nil, func(value object.Object) object.Object {
+		return &object.String{Value: string(value.Type())}
+	})
+	builtins["str"] = conversion("str", "Converts value to a STRING: a STRING is returned as is, any other value as it prints.", nil, func(value object.Object) object.Object {
+		return &object.String{Value: object.Str(value)}
+	})
+	builtins["bool"] = conversion("bool", "Converts value to a BOOLEAN: false for false and null, true for anything else.", nil, func(value object.Object) object.Object {
+		return nativeBoolToBooleanObject(isTruthy(value))
+	})
+	builtins["int"] = conversion("int", "Converts a number, a STRING holding a decimal integer or a BOOLEAN to an INTEGER, dropping the fraction of a FLOAT.", numberTypes, func(value object.Object) object.Object {
+		i, err := object.ToInt(value)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &object.Integer{Value: i}
+	})
+	builtins["float"] = conversion("float", "Converts a number, a STRING holding a number or a BOOLEAN to a FLOAT.", numberTypes, func(value object.Object) object.Object {
+		f, err := object.ToFloat(value)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &object.Float{Value: f}
+	})
+
+	for name, types := range map[string][]object.ObjectType{
+		"is_int":      {object.INTEGER_OBJ},
+		"is_float":    {object.FLOAT_OBJ},
+		"is_str":      {object.STRING_OBJ},
+		"is_bool":     {object.BOOLEAN_OBJ},
+		"is_null":     {object.NULL_OBJ},
+		"is_array":    {object.ARRAY_OBJ},
+		"is_hash":     {object.HASH_OBJ},
+		"is_function": {object.FUNCTION_OBJ, object.BUILTIN_OBJ},
+	} {
+		types := types
+		want := make([]string, len(types))
+		for i, t := range types {
+			want[i] = string(t)
+		}
+		builtins[name] = conversion(name, "Reports whether value is "+strings.Join(want, " or ")+".", nil, func(value object.Object) object.Object {
+			for _, t := range types {
+				if value.Type() == t {
+					return TRUE
+				}
+			}
+			return FALSE
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package evaluator
+
+import (
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// Hook lets a debugger follow evaluation. Statement is called before each statement of a
+// program or block runs, Call before a function is applied to its arguments and Return
+// once it has produced its result.
+type Hook interface {
+	// returning an error aborts evaluation with it, e.g. when the user ends a debug session
+	Statement(stmt ast.Statement, env *object.Environment) *object.Error
+	Call(call *ast.CallExpression, fn object.Object, args []object.Object)
+	Return(call *ast.CallExpression, result object.Object)
+}
+
+// DebugHook is notified by Eval when set, nil disables debugging
+var DebugHook Hook
+
+func debugStatement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if DebugHook == nil {
+		return nil
+	}
+	return DebugHook.Statement(stmt, env)
+}
@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// bindPattern binds the names in pattern to the parts of val they match, or returns an
+// error if val does not have the shape of the pattern
+func bindPattern(pattern ast.Expression, val object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if !ast.IsWildcard(pattern) {
+			env.Set(pattern.Value, val)
+		}
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok {
+			return newError("cannot destructure %s with the array pattern %s", val.Type(), pattern)
+		}
+		n := len(pattern.Elements)
+		if len(array.Elements) < n || (pattern.Rest == nil && len(array.Elements) > n) {
+			return newError("cannot destructure an array of %d elements with the array pattern %s", len(array.Elements), pattern)
+		}
+		for i, el := range pattern.Elements {
+			if err := bindPattern(el, array.Elements[i], env); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil && !ast.IsWildcard(pattern.Rest) {
+			rest := make([]object.Object, len(array.Elements)-n)
+			copy(rest, array.Elements[n:])
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return newError("cannot destructure %s with the hash pattern %s", val.Type(), pattern)
+		}
+		for i, key := range pattern.Keys {
+			pair, ok := hash.Pairs[(&object.String{Value: key.Value}).HashKey()]
+			if !ok {
+				return newError("cannot destructure a hash without the key %q with the hash pattern %s", key.Value, pattern)
+			}
+			target := pattern.Value(i)
+			if target == nil {
+				target = key
+			}
+			if err := bindPattern(target, pair.Value, env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchPattern reports whether val has the shape of pattern and equals its literals,
+// binding the names in pattern to the parts of val they match when it does
+func matchPattern(pattern ast.Expression, val object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return bindPattern(pattern, val, env) == nil
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok {
+			return false
+		}
+		n := len(pattern.Elements)
+		if len(array.Elements) < n || (pattern.Rest == nil && len(array.Elements) > n) {
+			return false
+		}
+		for i, el := range pattern.Elements {
+			if !matchPattern(el, array.Elements[i], env) {
+				return false
+			}
+		}
+		if pattern.Rest != nil && !ast.IsWildcard(pattern.Rest) {
+			rest := make([]object.Object, len(array.Elements)-n)
+			copy(rest, array.Elements[n:])
+			env.Set(pattern.Rest.Value, &object.Array{Elements: rest})
+		}
+		return true
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false
+		}
+		for i, key := range pattern.Keys {
+			pair, ok := hash.Pairs[(&object.String{Value: key.Value}).HashKey()]
+			if !ok {
+				return false
+			}
+			target := pattern.Value(i)
+			if target == nil {
+				target = key
+			}
+			if !matchPattern(target, pair.Value, env) {
+				return false
+			}
+		}
+		return true
+	case *ast.NullLiteral:
+		return val == NULL
+	}
+	// everything else is a literal, which matches values of its type that are equal to it
+	lit, ok := Eval(pattern, env).(object.Hashable)
+	if !ok {
+		return false
+	}
+	hashable, ok := val.(object.Hashable)
+	return ok && hashable.HashKey() == lit.HashKey()
+}
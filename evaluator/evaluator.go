@@ -27,10 +27,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env) // expression | integer | boolean | null
 		if isError(right) {
@@ -42,6 +48,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		// the right operand of ?? is only evaluated when it is needed
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
 		right := Eval(node.Right, env) // evaluate the operand
 		if isError(right) {
 			return right
@@ -64,25 +77,40 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Patterns: node.Patterns, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body}
 	case *ast.CallExpression:
 		if node.Function.TokenLiteral() == "quote" {
 			return quote(node.Arguments[0], env)
 		}
-		function := Eval(node.Function, env)
+		function, skip := evalCallee(node, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		if skip {
+			return NULL
+		}
+		args := evalArguments(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) { // check if we returned an error object
 			return args[0]
 		}
+		if DebugHook != nil {
+			DebugHook.Call(node, function, args)
+			result := applyFunction(function, args)
+			DebugHook.Return(node, result)
+			return result
+		}
 		return applyFunction(function, args)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -95,13 +123,40 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
+		return evalSliceExpression(node, left, env)
+	case *ast.MemberExpression:
+		left := Eval(node.Object, env)
+		if isError(left) {
+			return left
+		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
+		return evalMemberExpression(left, node.Property.Value)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.ImportExpression:
+		return DefaultImporter.Import(node.Path.Value)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	}
 
 	return nil
@@ -110,6 +165,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
+		if err := debugStatement(statement, env); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 
 		// Short-circuits any further statements from the program/block statements
@@ -127,6 +185,9 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, statement := range block.Statements {
+		if err := debugStatement(statement, env); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -160,6 +221,8 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	// boolean comparisons
@@ -191,12 +254,15 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	// you can only invert object literals in this language
-	if right.Type() != object.INTEGER_OBJ {
+	// you can only invert numbers in this language
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value} // invert the value
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value} // invert the value
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -211,6 +277,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -226,6 +295,42 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// evalFloatInfixExpression applies operator to two numbers of which at least one is a
+// FLOAT, the other being converted to a FLOAT
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := object.ToFloat(left)
+	rightVal, _ := object.ToFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
@@ -249,6 +354,32 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// evalMatchExpression evaluates the body of the first arm whose pattern matches the subject
+// and whose guard holds. The names bound by a pattern are only visible in its arm.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		if !matchPattern(arm.Pattern, subject, armEnv) {
+			continue
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return Eval(arm.Body, armEnv)
+	}
+	return newError("no arm of the match matches %s", subject.Inspect())
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -272,10 +403,59 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+// evalCallee evaluates what a call calls, looking up a method for value.name(args). skip
+// is set when an optional chain reached NULL, and the call gives NULL without calling.
+func evalCallee(node *ast.CallExpression, env *object.Environment) (object.Object, bool) {
+	member, ok := node.Function.(*ast.MemberExpression)
+	if !ok {
+		function := Eval(node.Function, env)
+		return function, node.Optional && function == NULL
+	}
+	receiver := Eval(member.Object, env)
+	if isError(receiver) {
+		return receiver, false
+	}
+	if member.Optional && receiver == NULL {
+		return NULL, true
+	}
+	function := lookupMethod(receiver, member.Property.Value)
+	return function, node.Optional && function == NULL
+}
+
+// evalArguments evaluates the arguments of a call, passing the elements of a spread
+// array as separate arguments
+func evalArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if !ok {
+			evaluated := Eval(e, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			result = append(result, evaluated)
+			continue
+		}
+		evaluated := Eval(spread.Value, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			return []object.Object{newError("cannot spread %s, want ARRAY", evaluated.Type())}
+		}
+		result = append(result, array.Elements...)
+	}
+	return result
+}
+
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
@@ -285,12 +465,60 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the parameters of fn to args. A missing argument takes the
+// default value of its parameter, evaluated after the parameters before it are bound.
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	if msg := checkArity(fn, len(args)); msg != "" {
+		return nil, newError("wrong number of arguments. %s", msg)
+	}
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		var val object.Object
+		if paramIdx < len(args) {
+			val = args[paramIdx]
+		} else {
+			val = Eval(fn.Defaults[paramIdx], env)
+			if err, ok := val.(*object.Error); ok {
+				return nil, err
+			}
+		}
+		if paramIdx < len(fn.Patterns) && fn.Patterns[paramIdx] != nil {
+			if err := bindPattern(fn.Patterns[paramIdx], val, env); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		env.Set(param.Value, val)
+	}
+	if fn.Rest != nil {
+		rest := &object.Array{Elements: []object.Object{}}
+		if len(args) > len(fn.Parameters) {
+			rest.Elements = append(rest.Elements, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, rest)
+	}
+	return env, nil
+}
+
+// checkArity describes what is wrong with calling fn with n arguments, in the words of
+// Builtin.CheckArity, or returns "" if nothing is
+func checkArity(fn *object.Function, n int) string {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+	max := len(fn.Parameters)
+	switch {
+	case n < required && (fn.Rest != nil || required < max):
+		return fmt.Sprintf("got=%d, want at least %d", n, required)
+	case n > max && fn.Rest == nil && required < max:
+		return fmt.Sprintf("got=%d, want at most %d", n, max)
+	case (n < required || n > max) && fn.Rest == nil:
+		return fmt.Sprintf("got=%d, want=%d", n, max)
 	}
-	return env
+	return ""
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -331,23 +559,47 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+// evalArrayIndexExpression counts a negative index from the end, so a[-1] is the last element
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	idx, ok := elementIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression returns the one character STRING at index, counting a
+// negative index from the end
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	chars := []rune(str.(*object.String).Value)
+	idx, ok := elementIndex(index.(*object.Integer).Value, len(chars))
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: string(chars[idx])}
+}
+
+// elementIndex turns an index into a position in a sequence of length elements, or
+// reports that it is out of range
+func elementIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	return idx, idx >= 0 && idx < int64(length)
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 	for keyNode, valueNode := range node.Pairs {
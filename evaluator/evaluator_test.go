@@ -195,6 +195,10 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"10 / (5 - 5)",
+			"division by zero",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -300,6 +304,263 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// arity
+		{"let add = fn(x, y) { x + y }; add(1)", "wrong number of arguments. got=1, want=2"},
+		{"let add = fn(x, y) { x + y }; add(1, 2, 3)", "wrong number of arguments. got=3, want=2"},
+		{"fn() { 1 }(1)", "wrong number of arguments. got=1, want=0"},
+		// defaults, which can use the parameters before them
+		{"let add = fn(x, y = 10) { x + y }; add(1)", 11},
+		{"let add = fn(x, y = 10) { x + y }; add(1, 2)", 3},
+		{"let f = fn(x = 1, y = x * 2) { y }; f()", 2},
+		{"let f = fn(x = 1, y = x * 2) { y }; f(5)", 10},
+		{"let f = fn(x, y = 10) { x + y }; f()", "wrong number of arguments. got=0, want at least 1"},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2, 3)", "wrong number of arguments. got=3, want at most 2"},
+		{"let f = fn(x = 1, y) { x + y }; f(1)", "wrong number of arguments. got=1, want=2"},
+		{"let f = fn(x = y) { x }; f()", "identifier not found: y"},
+		// rest parameters
+		{"let f = fn(x, ...rest) { len(rest) }; f(1, 2, 3)", 2},
+		{"let f = fn(x, ...rest) { len(rest) }; f(1)", 0},
+		{"let f = fn(...rest) { rest[1] }; f(1, 2, 3)", 2},
+		{"let f = fn(x, ...rest) { x }; f()", "wrong number of arguments. got=0, want at least 1"},
+		{"let f = fn(x, y = 2, ...rest) { x + y + len(rest) }; f(1)", 3},
+		// spread
+		{"let add = fn(x, y) { x + y }; add(...[1, 2])", 3},
+		{"let add = fn(x, y, z) { x + y + z }; let a = [2]; add(1, ...a, 3)", 6},
+		{"let f = fn(...rest) { len(rest) }; f(...[1, 2], ...[], ...[3])", 3},
+		{"len(...[[1, 2]])", 2},
+		{"let add = fn(x, y) { x + y }; add(...1)", "cannot spread INTEGER, want ARRAY"},
+		{"let add = fn(x, y) { x + y }; add(...[1])", "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a + b", 3},
+		{"let [a, ...rest] = [1, 2, 3]; len(rest) * 10 + rest[1]", 23},
+		{"let [a, ...rest] = [1]; len(rest)", 0},
+		{"let [[a, b], c] = [[1, 2], 3]; a + b + c", 6},
+		{`let {name, age} = {"name": "x", "age": 40}; age`, 40},
+		{`let {age: years} = {"age": 40}; years`, 40},
+		{`let {address: {city}} = {"address": {"city": 7}}; city`, 7},
+		{`let [first, {n}] = [1, {"n": 2}]; first + n`, 3},
+		{`let [] = []; 1`, 1},
+		// the same patterns as parameters
+		{"let f = fn([a, b], c) { a + b + c }; f([1, 2], 3)", 6},
+		{`let f = fn({x, y}) { x * y }; f({"x": 2, "y": 3})`, 6},
+		{"let f = fn([a, b] = [1, 2]) { a + b }; f()", 3},
+		// mismatches
+		{"let [a, b] = 1;", "cannot destructure INTEGER with the array pattern [a, b]"},
+		{"let [a, b] = [1];", "cannot destructure an array of 1 elements with the array pattern [a, b]"},
+		{"let [a, b] = [1, 2, 3];", "cannot destructure an array of 3 elements with the array pattern [a, b]"},
+		{"let [a, b, ...c] = [1];", "cannot destructure an array of 1 elements with the array pattern [a, b, ...c]"},
+		{`let {name} = [1];`, "cannot destructure ARRAY with the hash pattern {name}"},
+		{`let {name, age} = {"name": 1};`, `cannot destructure a hash without the key "age" with the hash pattern {name, age}`},
+		{`let {a: [b]} = {"a": 1};`, "cannot destructure INTEGER with the array pattern [b]"},
+		{"let f = fn([a]) { a }; f(1)", "cannot destructure INTEGER with the array pattern [a]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestNullAndOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"null == null", true},
+		{"1 == null", false},
+		{"!null", true},
+		{"null ?? 5", 5},
+		{"3 ?? 5", 3},
+		{"false ?? 5", false},
+		// the right operand is not evaluated unless it is needed
+		{"1 ?? missing", 1},
+		{"null ?? null ?? 7", 7},
+		{`let config = {"db": {"port": 5432}}; config?.["db"]?.["port"]`, 5432},
+		{`let config = {"db": {"port": 5432}}; config["cache"]?.["port"] ?? 6379`, 6379},
+		{`let config = null; config?.["db"]?.["port"]`, nil},
+		{"let f = null; f?.(1)", nil},
+		{"let f = fn(x) { x * 2 }; f?.(4)", 8},
+		// the arguments of a skipped call are not evaluated
+		{"let f = null; f?.(missing) ?? 2", 2},
+		{"[1, 2]?.[1]", 2},
+		{"match (null) { 0 => 0, null => 1 }", 1},
+		{"match (0) { null => 0, _ => 1 }", 1},
+		{`null["a"]`, "index operator not supported: NULL"},
+		{"null + 1", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMemberAndMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {"name": "x", "age": 40}; h.age`, 40},
+		{`let h = {"a": {"b": 2}}; h.a.b`, 2},
+		{`let h = {}; h.missing`, nil},
+		{`let h = null; h?.a?.b`, nil},
+		{`let h = {"a": null}; h.a?.b ?? 3`, 3},
+		{`"abc".upper()`, "ABC"},
+		{`" Hi ".trim().lower()`, "hi"},
+		{`"a,b,c".split(",").len()`, 3},
+		{`"monkey".contains("key")`, true},
+		{"[1, 2, 3].map(fn(x) { x * 2 })[2]", 6},
+		{"[1, 2, 3, 4].filter(fn(x) { x > 2 }).len()", 2},
+		{"[1, 2, 3].reduce(fn(sum, x) { sum + x }, 10)", 16},
+		{`["a", "b"].push("c").join("-")`, "a-b-c"},
+		{"[1, 2].first() + [1, 2].last()", 3},
+		{`{"b": 2, "a": 1}.keys().join("")`, "ab"},
+		{`{"b": 2, "a": 1}.values()[1]`, 2},
+		{`{"a": 1}.has("a")`, true},
+		// a function in a field is called when the type has no method of that name
+		{`let counter = {"step": 2, "next": fn(n) { n + 2 }}; counter.next(counter.step)`, 4},
+		{`let o = {"keys": fn() { 1 }}; o.keys().len()`, 1},
+		{`let h = null; h?.f(1)`, nil},
+		{`"abc".shout()`, "undefined method shout for STRING"},
+		{`{"a": 1}.b()`, "undefined method b for HASH"},
+		{`{"a": 1}.a()`, "not a function: INTEGER"},
+		{`"abc".upper(1)`, "wrong number of arguments to upper: got=1, want=0"},
+		{`[1].map(1)`, "not a function: INTEGER"},
+		{"5.x", "cannot read the field x of INTEGER"},
+		{`let h = {}; h.a.b`, "cannot read the field b of NULL"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%q: wrong string. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%q: unexpected object. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (2) { 1 => 10, 2 => 20, _ => 30 }", 20},
+		{"match (5) { 1 => 10, _ => 30 }", 30},
+		{"match (-1) { -1 => 1, _ => 2 }", 1},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (1 < 2) { false => 0, true => 1 }", 1},
+		// a literal only matches values of its own type
+		{`match ("1") { 1 => 1, _ => 2 }`, 2},
+		{"match (true) { 1 => 1, x => 2 }", 2},
+		{`match (1.5) { 1.5 => 1, _ => 2 }`, 1},
+		{`match (-2.5) { 2.5 => 1, -2.5 => 2, _ => 3 }`, 2},
+		{"match (7) { n => n * 2 }", 14},
+		{"match ([1, 2, 3]) { [] => 0, [a] => a, [a, ...rest] => a + len(rest) }", 3},
+		{"match ([1, 2]) { [a, b, c] => 0, [_, b] => b }", 2},
+		{"match ([0, 5]) { [1, b] => 1, [0, b] => b }", 5},
+		{`match ({"kind": "square", "side": 3}) { {kind: "circle"} => 0, {kind: "square", side} => side * side }`, 9},
+		{`match ({"a": 1}) { {b} => b, {a} => a }`, 1},
+		{"match (3) { n if n > 5 => 1, n if n > 2 => 2, _ => 3 }", 2},
+		// bindings stay inside their arm
+		{"let n = 1; match (2) { n => n }; n", 1},
+		{"let f = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + f(rest) } }; f([1, 2, 3])", 6},
+		{"match (1) { 2 => 2 }", "no arm of the match matches 1"},
+		{"match (1) { n if m => n }", "identifier not found: m"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -323,6 +584,162 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let user = {"name": "Ann"}; let items = [1, 2]; "Hello ${user.name}, you have ${len(items)} items"`, "Hello Ann, you have 2 items"},
+		{`"${1 + 2}${true}${null}"`, "3truenull"},
+		{`let v = null; "${v}|" + str(v)`, "null|null"},
+		{`"${[1, "a"]} ${{"k": 2}}"`, `[1,a] {k: 2}`},
+		{`let s = "x"; "${"<${s}>"}"`, "<x>"},
+		{`let f = fn(n) { "n=${n}" }; f(4)`, "n=4"},
+		{`"$ and {} stay"`, "$ and {} stay"},
+		{`"${y}"`, "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		switch obj := testEval(tt.input).(type) {
+		case *object.String:
+			if obj.Value != tt.expected {
+				t.Errorf("%q: wrong string. expected=%q, got=%q", tt.input, tt.expected, obj.Value)
+			}
+		case *object.Error:
+			if obj.Message != tt.expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, tt.expected, obj.Message)
+			}
+		default:
+			t.Errorf("%q: unexpected object. got=%T (%+v)", tt.input, obj, obj)
+		}
+	}
+}
+
+func TestFloatExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2.5", 2.5},
+		{"-1.5", -1.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2 * 0.25 - 1", -0.5},
+		{"1.5 > 1", true},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.0 / 0", "division by zero"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%q: expected the error %q, got %v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type(1.5)`, "FLOAT"},
+		{`type("a")`, "STRING"},
+		{`type(null)`, "NULL"},
+		{`type(len)`, "BUILTIN"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`str(42)`, "42"},
+		{`str(2.0)`, "2.0"},
+		{`str("a")`, "a"},
+		{`str([1, true])`, "[1,true]"},
+		{`str(null)`, "null"},
+		{`int("42")`, 42},
+		{`int(" -7 ")`, -7},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(true)`, 1},
+		{`int(7)`, 7},
+		{`float("2.5")`, 2.5},
+		{`float(2)`, 2.0},
+		{`float(false)`, 0.0},
+		{`bool(0)`, true},
+		{`bool("")`, true},
+		{`bool(null)`, false},
+		{`bool(false)`, false},
+		{`int("4" + "2") + 1`, 43},
+		{`is_int(1)`, true},
+		{`is_int(1.0)`, false},
+		{`is_float(1.0)`, true},
+		{`is_str("a")`, true},
+		{`is_bool(null)`, false},
+		{`is_null(null)`, true},
+		{`is_array([])`, true},
+		{`is_hash({})`, true},
+		{`is_function(len)`, true},
+		{`is_function(fn(x) { x })`, true},
+		{`is_function(1)`, false},
+		{`int("abc")`, errorMessage(`cannot convert "abc" to INTEGER`)},
+		{`int("1.5")`, errorMessage(`cannot convert "1.5" to INTEGER`)},
+		{`int("99999999999999999999")`, errorMessage(`cannot convert "99999999999999999999" to INTEGER, it is out of range`)},
+		{`int(float("1e19"))`, errorMessage("cannot convert 10000000000000000000.0 to INTEGER, it is out of range")},
+		{`int(null)`, errorMessage("cannot convert NULL to INTEGER")},
+		{`float("abc")`, errorMessage(`cannot convert "abc" to FLOAT`)},
+		{`float("inf")`, errorMessage(`cannot convert "inf" to FLOAT`)},
+		{`float([1])`, errorMessage("cannot convert ARRAY to FLOAT")},
+		{`str(1, 2)`, errorMessage("wrong number of arguments. got=2, want=1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%q: expected the string %q, got %v", tt.input, expected, evaluated)
+			}
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != string(expected) {
+				t.Errorf("%q: expected the error %q, got %v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+// errorMessage marks an expected result as the message of an error rather than a string
+type errorMessage string
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%v, want=%v", result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 	evaluated := testEval(input)
@@ -345,6 +762,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`last(push([1, 2], 3))`, 3},
+		{`len(push([], 1))`, 1},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
 	}
 
 	for _, tt := range tests {
@@ -423,6 +843,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -438,6 +866,71 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexAndSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[3]`, nil},
+		{`"monkey"[1:3]`, "on"},
+		{`"monkey"[:3]`, "mon"},
+		{`"monkey"[3:]`, "key"},
+		{`"monkey"[-3:]`, "key"},
+		{`"monkey"[::2]`, "mne"},
+		{`"monkey"[::-1]`, "yeknom"},
+		{`"monkey"[10:20]`, ""},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-4]`, "é"},
+		{`"日本語"[1:]`, "本語"},
+		{`"héllo"[::-1]`, "olléh"},
+		{`let s = "naïve"; s[len(s) - 1]`, "e"},
+		{"[1, 2, 3, 4, 5][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4, 5][:-2]", []int64{1, 2, 3}},
+		{"[1, 2, 3, 4, 5][::2]", []int64{1, 3, 5}},
+		{"[1, 2, 3, 4, 5][4:1:-1]", []int64{5, 4, 3}},
+		{"[1, 2, 3, 4, 5][-2::-2]", []int64{4, 2}},
+		{"[1, 2, 3][:]", []int64{1, 2, 3}},
+		{"[1, 2, 3][-10:10]", []int64{1, 2, 3}},
+		{"[1, 2, 3][2:1]", []int64{}},
+		{"let a = null; a?.[1:]", nil},
+		{"[1, 2][::0]", "slice step cannot be zero"},
+		{`[1, 2]["a":]`, "slice bounds must be INTEGER, got STRING"},
+		{"5[1:]", "slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok || len(array.Elements) != len(expected) {
+				t.Errorf("%q: expected %d elements, got %v", tt.input, len(expected), evaluated)
+				continue
+			}
+			for i, want := range expected {
+				testIntegerObject(t, array.Elements[i], want)
+			}
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%q: wrong string. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%q: unexpected object. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
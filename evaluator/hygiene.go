@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/josh-weston/go_interpreter/ast"
+)
+
+var gensymCounter int64
+
+// gensym returns a fresh name based on name. The '#' cannot appear in an identifier in
+// source code, so the name cannot clash with one written by the user.
+func gensym(name string) string {
+	return fmt.Sprintf("%s#%d", name, atomic.AddInt64(&gensymCounter, 1))
+}
+
+// hygienic returns a copy of a macro body in which the names bound by the code it quotes
+// are replaced by fresh ones. Without this a let or parameter introduced by the expansion
+// could capture a variable passed in by the caller, or overwrite one of the caller's
+// variables since blocks do not introduce a scope. Code spliced in by unquote is left as is.
+func hygienic(body *ast.BlockStatement) *ast.BlockStatement {
+	body = ast.Clone(body).(*ast.BlockStatement)
+	ast.Inspect(body, func(node ast.Node) bool {
+		if !isQuoteCall(node) {
+			return true
+		}
+		for _, arg := range node.(*ast.CallExpression).Arguments {
+			renameBindings(arg)
+		}
+		return false
+	})
+	return body
+}
+
+func isQuoteCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "quote"
+}
+
+// inspectTemplate calls f for the nodes of quoted code, skipping the unquote and
+// unquote_splice calls
+func inspectTemplate(template ast.Node, f func(ast.Node)) {
+	ast.Inspect(template, func(node ast.Node) bool {
+		if isUnquoteCall(node) || isUnquoteSpliceCall(node) {
+			return false
+		}
+		f(node)
+		return true
+	})
+}
+
+// renameBindings gives the names bound by a template fresh names, in the bindings and
+// in the uses within their scope: the body of a function for its parameters, the
+// statements after a let, and the guard and body of a match arm
+func renameBindings(template ast.Node) {
+	r := &renamer{keys: map[*ast.Identifier]bool{}}
+	inspectTemplate(template, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.MemberExpression:
+			r.keys[node.Property] = true
+		case *ast.HashPattern:
+			// {name} becomes {name: name} so the binding can be renamed apart from the key
+			if node.Values == nil {
+				node.Values = make([]ast.Expression, len(node.Keys))
+			}
+			for i, key := range node.Keys {
+				r.keys[key] = true
+				if node.Values[i] == nil {
+					copied := *key
+					node.Values[i] = &copied
+				}
+			}
+		}
+	})
+	r.rename(template, map[string]string{})
+}
+
+type renamer struct {
+	keys map[*ast.Identifier]bool // the keys of hash patterns and properties, which are not renamed
+}
+
+// bind returns scope extended with fresh names for idents
+func (r *renamer) bind(scope map[string]string, idents ...*ast.Identifier) map[string]string {
+	inner := make(map[string]string, len(scope)+len(idents))
+	for name, fresh := range scope {
+		inner[name] = fresh
+	}
+	for _, ident := range idents {
+		if ident != nil {
+			inner[ident.Value] = gensym(ident.Value)
+		}
+	}
+	return inner
+}
+
+// rename renames the identifiers in node that are bound in scope, maps from the names
+// written in the template to their fresh names
+func (r *renamer) rename(node ast.Node, scope map[string]string) {
+	if isUnquoteCall(node) || isUnquoteSpliceCall(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *ast.Identifier:
+		if fresh, ok := scope[node.Value]; ok && !r.keys[node] {
+			node.Value = fresh
+			node.Token.Literal = fresh
+		}
+		return
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			let, ok := stmt.(*ast.LetStatement)
+			if !ok {
+				r.rename(stmt, scope)
+				continue
+			}
+			inner := r.bind(scope, append(ast.PatternIdentifiers(let.Pattern), let.Name)...)
+			// a function may call itself by the name it is bound to, any other
+			// value is evaluated before the binding exists
+			if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+				r.rename(let.Value, inner)
+			} else {
+				r.rename(let.Value, scope)
+			}
+			if let.Pattern != nil {
+				r.rename(let.Pattern, inner)
+			} else {
+				r.rename(let.Name, inner)
+			}
+			scope = inner
+		}
+		return
+	case *ast.FunctionLiteral:
+		idents := append([]*ast.Identifier{}, node.Parameters...)
+		for _, pattern := range node.Patterns {
+			idents = append(idents, ast.PatternIdentifiers(pattern)...)
+		}
+		scope = r.bind(scope, append(idents, node.Rest)...)
+	case *ast.MatchArm:
+		scope = r.bind(scope, ast.PatternIdentifiers(node.Pattern)...)
+	}
+	for _, child := range ast.Children(node) {
+		r.rename(child, scope)
+	}
+}
@@ -1,85 +1,298 @@
 package evaluator
 
 import (
+	"fmt"
+
 	"github.com/josh-weston/go_interpreter/ast"
 	"github.com/josh-weston/go_interpreter/object"
+	"github.com/josh-weston/go_interpreter/token"
 )
 
+// DefineMacros adds the top-level macro definitions of program to env and takes them out of
+// the program. The statements are copied to a new slice, so other programs sharing them
+// are not affected. Definitions in blocks are left to ExpandMacros, which scopes them to
+// their block.
 func DefineMacros(program *ast.Program, env *object.Environment) {
-	definitions := []int{}
+	program.Statements = defineMacros(program.Statements, env)
+}
 
-	for i, statement := range program.Statements {
+// defineMacros adds the macro definitions among statements to env and returns the other
+// statements
+func defineMacros(statements []ast.Statement, env *object.Environment) []ast.Statement {
+	rest := make([]ast.Statement, 0, len(statements))
+	for _, statement := range statements {
 		if isMacroDefinition(statement) {
 			addMacro(statement, env)
-			definitions = append(definitions, i)
+			continue
 		}
+		rest = append(rest, statement)
 	}
-
-	for i := len(definitions) - 1; i >= 0; i = i - 1 {
-		definitionIndex := definitions[i]
-		// this is a splice operation
-		program.Statements = append(
-			program.Statements[:definitionIndex],
-			program.Statements[definitionIndex+1:]...,
-		)
-	}
+	return rest
 }
 
 func isMacroDefinition(node ast.Statement) bool {
-	letStatement, ok := node.(*ast.LetStatement)
-	if !ok {
+	letStatement, ok := macroLet(node)
+	if !ok || letStatement.Name == nil {
 		return false
 	}
 	_, ok = letStatement.Value.(*ast.MacroLiteral)
 	return ok
 }
 
+// macroLet returns the let statement of a statement that may define a macro, looking
+// through an export
+func macroLet(node ast.Statement) (*ast.LetStatement, bool) {
+	if export, ok := node.(*ast.ExportStatement); ok {
+		return export.Statement, export.Statement != nil
+	}
+	letStatement, ok := node.(*ast.LetStatement)
+	return letStatement, ok
+}
+
 func addMacro(stmt ast.Statement, env *object.Environment) {
-	letStatement, _ := stmt.(*ast.LetStatement)
+	letStatement, _ := macroLet(stmt)
 	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
 	macro := &object.Macro{
 		Parameters: macroLiteral.Parameters,
+		Rest:       macroLiteral.Rest,
 		Env:        env,
 		Body:       macroLiteral.Body,
 	}
 	env.Set(letStatement.Name.Value, macro) // hoists our macros (stores it in global map for access everywhere)
 }
 
-func ExpandMacros(program *ast.Program, env *object.Environment) ast.Node {
-	return ast.Modify(program, func(node ast.Node) ast.Node {
-		callExpression, ok := node.(*ast.CallExpression)
+// MacroError is a macro call that could not be expanded
+type MacroError struct {
+	Pos   token.Position // the call site
+	Macro string
+	Msg   string
+}
+
+func (e *MacroError) Error() string {
+	if e.Macro == "" {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: in expansion of %s: %s", e.Pos, e.Macro, e.Msg)
+}
+
+// MacroDepthLimit is how deeply expansions may nest, a macro call in the expansion of a
+// macro call counting as one level. It stops macros that expand to themselves forever.
+var MacroDepthLimit = 100
+
+// ExpandMacros returns a copy of program with the calls to macros replaced by their
+// expansions, which are expanded in turn until no macro calls are left. The program itself
+// is left untouched. The macros in env are visible everywhere; a macro defined in a block
+// is visible in that block only, and `let name = import "path"` makes the macros exported
+// by the module callable as name.macro(...) or name["macro"](...). A call that cannot be expanded is left in
+// place and reported; the program should not be run if there are any errors.
+func ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, []*MacroError) {
+	e := &expander{scopes: make(map[*ast.CallExpression]*object.Environment)}
+	expanded := e.expand(ast.Clone(program), env, 0)
+	return expanded.(*ast.Program), e.errs
+}
+
+type expander struct {
+	scopes map[*ast.CallExpression]*object.Environment // the macros visible at each call
+	errs   []*MacroError
+}
+
+// expand expands the macro calls in node, which is code visible to the macros in env
+func (e *expander) expand(node ast.Node, env *object.Environment, depth int) ast.Node {
+	ast.Walk(&scoper{e: e, envs: []*object.Environment{env}}, node)
+	expanded, err := ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
 		if !ok {
 			return node
 		}
-		macro, ok := isMacroCall(callExpression, env)
+		env, ok := e.scopes[call]
 		if !ok {
-			return node
+			return node // quoted code is expanded once it is unquoted into the program
+		}
+		if ident, ok := call.Function.(*ast.Identifier); ok && ident.Value == "macroexpand" {
+			return e.macroexpand(call, env, depth)
 		}
-		args := quoteArgs(callExpression)
-		evalEnv := extendMacroEnv(macro, args)
-		evaluated := Eval(macro.Body, evalEnv)
-		quote, ok := evaluated.(*object.Quote)
+		macro, ok := isMacroCall(call, env)
 		if !ok {
-			panic("we only support returning AST-nodes from macros")
+			return node
 		}
-		return quote.Node
+		return e.call(call, macro, env, depth)
 	})
+	if err != nil {
+		// the expansion of some macro does not fit where it was called
+		macroErr := &MacroError{Msg: err.Error()}
+		if mismatch, ok := err.(*ast.MismatchError); ok {
+			macroErr.Pos = mismatch.Parent.Pos()
+		}
+		e.errs = append(e.errs, macroErr)
+	}
+	return expanded
 }
 
-func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
-	identifier, ok := exp.Function.(*ast.Identifier)
-	if !ok {
-		return nil, false
+func (e *expander) fail(call *ast.CallExpression, format string, args ...interface{}) ast.Node {
+	e.errs = append(e.errs, &MacroError{
+		Pos:   call.Pos(),
+		Macro: call.Function.String(),
+		Msg:   fmt.Sprintf(format, args...),
+	})
+	return call
+}
+
+// call returns the expansion of a call to macro
+func (e *expander) call(call *ast.CallExpression, macro *object.Macro, env *object.Environment, depth int) ast.Node {
+	if depth >= MacroDepthLimit {
+		return e.fail(call, "expansions nested more than %d deep, the macro may expand to itself", MacroDepthLimit)
 	}
-	obj, ok := env.Get(identifier.Value)
-	if !ok {
-		return nil, false
+	if macro.Rest != nil && len(call.Arguments) < len(macro.Parameters) {
+		return e.fail(call, "wrong number of arguments. got=%d, want at least %d", len(call.Arguments), len(macro.Parameters))
+	}
+	if macro.Rest == nil && len(call.Arguments) != len(macro.Parameters) {
+		return e.fail(call, "wrong number of arguments. got=%d, want=%d", len(call.Arguments), len(macro.Parameters))
+	}
+	args := quoteArgs(call)
+	evalEnv := extendMacroEnv(macro, args)
+	evaluated := Eval(hygienic(macro.Body), evalEnv)
+	switch evaluated := evaluated.(type) {
+	case *object.Quote:
+		if evaluated.Node == nil {
+			return e.fail(call, "the quoted code is empty")
+		}
+		// the expansion is code at the call site, and may call or define macros itself
+		return e.expand(evaluated.Node, env, depth+1)
+	case *object.Error:
+		return e.fail(call, "%s", evaluated.Message)
+	case nil:
+		return e.fail(call, "the macro must return a quote, got nothing")
+	default:
+		return e.fail(call, "the macro must return a quote, got %s", evaluated.Type())
+	}
+}
+
+// macroexpand replaces macroexpand(quote(code)) by quote(expanded code), to see what
+// the macros used by code turn it into
+func (e *expander) macroexpand(call *ast.CallExpression, env *object.Environment, depth int) ast.Node {
+	if len(call.Arguments) != 1 || !isQuoteCall(call.Arguments[0]) {
+		return e.fail(call, "macroexpand needs quoted code, as in macroexpand(quote(m(x)))")
 	}
-	macro, ok := obj.(*object.Macro)
+	quoted := call.Arguments[0].(*ast.CallExpression)
+	if len(quoted.Arguments) != 1 {
+		return e.fail(call, "quote takes 1 argument, got %d", len(quoted.Arguments))
+	}
+	expanded, ok := e.expand(quoted.Arguments[0], env, depth).(ast.Expression)
 	if !ok {
-		return nil, false
+		return e.fail(call, "the expansion is not an expression")
+	}
+	quoted.Arguments[0] = expanded
+	return quoted
+}
+
+// scoper records the macros visible at each call, defining the macros of each block in
+// an environment of its own. Quoted code is skipped apart from its unquote calls.
+type scoper struct {
+	e    *expander
+	envs []*object.Environment // innermost last
+}
+
+func (s *scoper) Pre(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Program:
+		s.push(&node.Statements)
+	case *ast.BlockStatement:
+		s.push(&node.Statements)
+	case *ast.CallExpression:
+		if isQuoteCall(node) {
+			for _, arg := range node.Arguments {
+				ast.Inspect(arg, func(n ast.Node) bool {
+					if isUnquoteCall(n) || isUnquoteSpliceCall(n) {
+						for _, arg := range n.(*ast.CallExpression).Arguments {
+							ast.Walk(s, arg)
+						}
+						return false
+					}
+					return true
+				})
+			}
+			return false
+		}
+		s.e.scopes[node] = s.envs[len(s.envs)-1]
+	}
+	return true
+}
+
+func (s *scoper) Post(node ast.Node) {
+	switch node.(type) {
+	case *ast.Program, *ast.BlockStatement:
+		s.envs = s.envs[:len(s.envs)-1]
 	}
-	return macro, true
+}
+
+// push opens the scope of a block, taking its macro definitions out of statements
+func (s *scoper) push(statements *[]ast.Statement) {
+	env := object.NewEnclosedEnvironment(s.envs[len(s.envs)-1])
+	*statements = defineMacros(*statements, env)
+	for _, statement := range *statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+		imp, ok := let.Value.(*ast.ImportExpression)
+		if !ok || imp.Path == nil {
+			continue
+		}
+		// a module that cannot be loaded is reported when it is imported at run time
+		if mod, err := DefaultImporter.ImportMacros(imp.Path.Value); err == nil && len(mod.Macros) != 0 {
+			env.Set(let.Name.Value, mod)
+		}
+	}
+	s.envs = append(s.envs, env)
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	switch function := exp.Function.(type) {
+	case *ast.Identifier:
+		obj, ok := env.Get(function.Value)
+		if !ok {
+			return nil, false
+		}
+		macro, ok := obj.(*object.Macro)
+		return macro, ok
+	case *ast.IndexExpression:
+		// module["macro"]
+		ident, ok := function.Left.(*ast.Identifier)
+		if !ok {
+			return nil, false
+		}
+		name, ok := function.Index.(*ast.StringLiteral)
+		if !ok {
+			return nil, false
+		}
+		obj, ok := env.Get(ident.Value)
+		if !ok {
+			return nil, false
+		}
+		mod, ok := obj.(*object.Module)
+		if !ok {
+			return nil, false
+		}
+		macro, ok := mod.Macros[name.Value]
+		return macro, ok
+	case *ast.MemberExpression:
+		// module.macro
+		ident, ok := function.Object.(*ast.Identifier)
+		if !ok || function.Optional {
+			return nil, false
+		}
+		obj, ok := env.Get(ident.Value)
+		if !ok {
+			return nil, false
+		}
+		mod, ok := obj.(*object.Module)
+		if !ok {
+			return nil, false
+		}
+		macro, ok := mod.Macros[function.Property.Value]
+		return macro, ok
+	}
+	return nil, false
 }
 
 func quoteArgs(exp *ast.CallExpression) []*object.Quote {
@@ -95,5 +308,13 @@ func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environme
 	for paramIdx, param := range macro.Parameters {
 		extended.Set(param.Value, args[paramIdx])
 	}
+	if macro.Rest != nil {
+		// the remaining arguments, ready for unquote_splice
+		rest := &object.Array{Elements: []object.Object{}}
+		for _, arg := range args[len(macro.Parameters):] {
+			rest.Elements = append(rest.Elements, arg)
+		}
+		extended.Set(macro.Rest.Value, rest)
+	}
 	return extended
 }
@@ -1,6 +1,10 @@
 package evaluator
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/josh-weston/go_interpreter/ast"
@@ -101,9 +105,449 @@ func TestExpandMacros(t *testing.T) {
 		program := testParseProgram(tt.input)
 		env := object.NewEnvironment()
 		DefineMacros(program, env)
-		expanded := ExpandMacros(program, env)
+		expanded, errs := ExpandMacros(program, env)
+		if len(errs) != 0 {
+			t.Fatalf("unexpected errors %v", errs)
+		}
 		if expanded.String() != expected.String() {
 			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
 		}
 	}
 }
+
+func TestExpandMacrosLeavesProgramUntouched(t *testing.T) {
+	input := `
+	let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+	twice(1 + 2);
+	twice(4);
+	`
+	expected := testParseProgram("((1 + 2) + (1 + 2)); (4 + 4)").String()
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	before := program.String()
+
+	// expanding twice gives the same program, which a corrupted macro body would not
+	for i := 0; i < 2; i++ {
+		expanded, errs := ExpandMacros(program, env)
+		if len(errs) != 0 {
+			t.Fatalf("unexpected errors %v", errs)
+		}
+		if expanded.String() != expected {
+			t.Errorf("expansion %d: want=%q, got=%q", i+1, expected, expanded.String())
+		}
+		if program.String() != before {
+			t.Fatalf("expansion %d modified the program: %q", i+1, program.String())
+		}
+	}
+
+	// the argument appears twice in the expansion but must not be shared
+	expanded, _ := ExpandMacros(program, env)
+	sum := expanded.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	if sum.Left == sum.Right {
+		t.Errorf("the unquoted argument is shared by both operands")
+	}
+}
+
+func TestQuoteEvaluatedAgain(t *testing.T) {
+	// each call evaluates the same quote node with a different x
+	input := `
+	let f = fn(x) { quote(unquote(x) * 2) };
+	[f(1), f(2)]
+	`
+	array, ok := testEval(input).(*object.Array)
+	if !ok || len(array.Elements) != 2 {
+		t.Fatalf("expected an array of two quotes, got %v", array)
+	}
+	for i, want := range []string{"(1 * 2)", "(2 * 2)"} {
+		quote, ok := array.Elements[i].(*object.Quote)
+		if !ok {
+			t.Fatalf("element %d is not a Quote. got=%T", i, array.Elements[i])
+		}
+		if quote.Node.String() != want {
+			t.Errorf("element %d: want=%q, got=%q", i, want, quote.Node.String())
+		}
+	}
+}
+
+func testExpandEval(input string) object.Object {
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded, _ := ExpandMacros(program, env)
+	return Eval(expanded, env)
+}
+
+func TestMacroHygiene(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// the tmp of swap must not overwrite the caller's tmp
+		{
+			`
+			let swap = macro(a, b) { quote(if (true) { let tmp = unquote(a); [unquote(b), tmp] }) };
+			let tmp = 10;
+			let pair = swap(1, 2);
+			[pair, tmp]
+			`,
+			"[[2,1],10]",
+		},
+		// nor capture an argument named tmp
+		{
+			`
+			let swap = macro(a, b) { quote(if (true) { let tmp = unquote(a); [unquote(b), tmp] }) };
+			let tmp = 10;
+			let y = 2;
+			swap(y, tmp)
+			`,
+			"[10,2]",
+		},
+		// the parameter c of unless must not capture the caller's c
+		{
+			`
+			let unless = macro(cond, cons, alt) {
+				quote(fn(c) { if (!c) { unquote(cons) } else { unquote(alt) } }(unquote(cond)))
+			};
+			let c = "user";
+			unless(false, c, "no")
+			`,
+			"user",
+		},
+		// names the template uses but does not bind still refer to the call site
+		{
+			`
+			let add = macro(a) { quote(unquote(a) + offset) };
+			let offset = 5;
+			add(1)
+			`,
+			"6",
+		},
+		// a parameter is renamed in the body of its function only
+		{
+			`
+			let x = 10;
+			let m = macro(a) { quote(fn(x) { x + 1 }(unquote(a)) + x) };
+			m(1)
+			`,
+			"12",
+		},
+		// and a let in the statements after it
+		{
+			`
+			let n = 1;
+			let m = macro() { quote(if (true) { let before = n; let n = 5; [before, n] }) };
+			m()
+			`,
+			"[1,5]",
+		},
+		// a function bound by a let can call itself
+		{
+			`
+			let m = macro(a) { quote(if (true) { let f = fn(n) { if (n < 1) { 0 } else { n + f(n - 1) } }; f(unquote(a)) }) };
+			m(3)
+			`,
+			"6",
+		},
+	}
+
+	for _, tt := range tests {
+		result := testExpandEval(tt.input)
+		if result.Inspect() != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestMacroHygieneRenamesPerExpansion(t *testing.T) {
+	program := testParseProgram(`
+	let m = macro(x) { quote(fn(v) { v + unquote(x) }) };
+	m(1); m(2);
+	`)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded, _ := ExpandMacros(program, env)
+
+	names := []string{}
+	for i, stmt := range expanded.Statements {
+		fn := stmt.(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		param := fn.Parameters[0].Value
+		if !strings.HasPrefix(param, "v#") {
+			t.Errorf("expected a fresh name for v, got %q", param)
+		}
+		if want := fmt.Sprintf("(%s + %d)", param, i+1); fn.Body.String() != want {
+			t.Errorf("the use of v was not renamed with its parameter: %s", fn)
+		}
+		names = append(names, param)
+	}
+	if len(names) != 2 || names[0] == names[1] {
+		t.Errorf("expected each expansion to get its own name, got %v", names)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let m = macro(a) { quote(unquote(a)) };\nm(1, 2)",
+			[]string{"2:1: in expansion of m: wrong number of arguments. got=2, want=1"}},
+		{"let m = macro() { 1 };\nm()",
+			[]string{"2:1: in expansion of m: the macro must return a quote, got INTEGER"}},
+		{"let m = macro() { quote(unquote(x)) };\nlet y = m();",
+			[]string{"2:9: in expansion of m: unquote: identifier not found: x"}},
+		{"let m = macro() { quote(unquote(fn(x) { x })) };\nm()",
+			[]string{"2:1: in expansion of m: unquote: a FUNCTION cannot be converted to code"}},
+		// every failing call is reported
+		{"let m = macro(a) { quote(unquote(a)) };\nm();\nputs(m(1, 2));",
+			[]string{
+				"2:1: in expansion of m: wrong number of arguments. got=0, want=1",
+				"3:6: in expansion of m: wrong number of arguments. got=2, want=1",
+			}},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, errs := ExpandMacros(program, env)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%q: expected %d errors, got %v", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, want := range tt.expected {
+			if got := errs[i].Error(); got != want {
+				t.Errorf("%q: expected %q, got %q", tt.input, want, got)
+			}
+		}
+	}
+}
+
+func TestUnquoteValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro() { quote(unquote("a" + "b")) }; m()`, "ab"},
+		{`let m = macro() { quote(unquote(0 - 5) * 2) }; m()`, "-10"},
+		{`let m = macro() { quote(len(unquote([1, "two", true]))) }; m()`, "3"},
+		{`let m = macro() { quote(unquote({"k": [1, 2]})["k"][1]) }; m()`, "2"},
+		{`let m = macro() { quote(unquote(if (false) { 1 })) }; m()`, "NULL"},
+		{`let m = macro() { quote(unquote(null) ?? 4) }; m()`, "4"},
+	}
+
+	for _, tt := range tests {
+		if result := testExpandEval(tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestUnquoteSplice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// into an argument list
+		{`let apply = macro(f, ...args) { quote(unquote(f)(unquote_splice(args))) };
+		apply(len, "abc")`, 3},
+		{`let add = fn(a, b) { a + b };
+		let apply = macro(f, ...args) { quote(unquote(f)(1, unquote_splice(args))) };
+		apply(add, 2)`, 3},
+		// into an array literal, with the arguments evaluated once spliced
+		{`let list = macro(...items) { quote([0, unquote_splice(items)]) };
+		len(list(1 + 1, 2 * 3))`, 3},
+		{`let list = macro(...items) { quote([0, unquote_splice(items)]) };
+		list(1 + 1, 2 * 3)[2]`, 6},
+		{`let list = macro(...items) { quote([unquote_splice(items)]) };
+		len(list())`, 0},
+		// into a block, one statement per element
+		{`let do = macro(...body) { quote(if (true) { unquote_splice(body) }) };
+		let x = 1;
+		do(puts(x), x + 10)`, 11},
+		{`let three = macro() { quote([unquote_splice([1, 2, 3])]) };
+		len(three())`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testExpandEval(tt.input)
+		if !testIntegerObject(t, evaluated, int64(tt.expected.(int))) {
+			t.Errorf("input %q", tt.input)
+		}
+	}
+}
+
+func TestUnquoteSpliceErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro(a, ...rest) { quote(unquote(a)) };\nm()",
+			"2:1: in expansion of m: wrong number of arguments. got=0, want at least 1"},
+		{"let m = macro(a) { quote(f(unquote_splice(a))) };\nm(1)",
+			"2:1: in expansion of m: unquote: unquote_splice needs an ARRAY, got QUOTE"},
+		{"let m = macro() { quote(1 + unquote_splice([1])) };\nm()",
+			"2:1: in expansion of m: unquote: unquote_splice can only be used in argument lists, array literals and blocks"},
+		{"let m = macro() { quote(f(unquote_splice([fn() { 1 }]))) };\nm()",
+			"2:1: in expansion of m: unquote: a FUNCTION cannot be converted to code"},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, errs := ExpandMacros(program, env)
+		if len(errs) != 1 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: expected %q, got %v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestScopedMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// defined in a function body, and usable before the definition within the block
+		{`let f = fn(x) {
+			let r = twice(x);
+			let twice = macro(a) { quote(unquote(a) * 2) };
+			r
+		};
+		f(4)`, 8},
+		// in a block, shadowing a top-level macro
+		{`let m = macro() { quote(1) };
+		let x = if (true) { let m = macro() { quote(2) }; m() };
+		x + m()`, 3},
+		// not visible outside the block
+		{`let f = fn() { let m = macro() { quote(1) }; m() };
+		m()`, "identifier not found: m"},
+		// expanding to another macro call
+		{`let inc = macro(a) { quote(unquote(a) + 1) };
+		let twice = macro(a) { quote(inc(inc(unquote(a)))) };
+		twice(1)`, 3},
+		// expanding to a macro definition
+		{`let define = macro(value) { quote(if (true) { let m = macro() { quote(unquote(value)) }; m() }) };
+		define(5)`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testExpandEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%q: expected error %q, got %v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestMacroDepthLimit(t *testing.T) {
+	previous := MacroDepthLimit
+	MacroDepthLimit = 10
+	defer func() { MacroDepthLimit = previous }()
+
+	program := testParseProgram("let forever = macro() { quote(forever()) };\nforever()")
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	_, errs := ExpandMacros(program, env)
+	expected := "in expansion of forever: expansions nested more than 10 deep, the macro may expand to itself"
+	if len(errs) != 1 || !strings.HasSuffix(errs[0].Error(), expected) {
+		t.Fatalf("expected %q, got %v", expected, errs)
+	}
+}
+
+func TestMacroExpand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let unless = macro(cond, cons) { quote(if (!(unquote(cond))) { unquote(cons) }) };
+		macroexpand(quote(unless(x > 1, y)))`, "if(!(x > 1)) y"},
+		{`let inc = macro(a) { quote(unquote(a) + 1) };
+		let twice = macro(a) { quote(inc(inc(unquote(a)))) };
+		macroexpand(quote(twice(x)))`, "((x + 1) + 1)"},
+		// quoted code is left alone without macroexpand
+		{`let inc = macro(a) { quote(unquote(a) + 1) };
+		quote(inc(x))`, "inc(x)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testExpandEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("%q: expected a quote, got %v", tt.input, evaluated)
+			continue
+		}
+		if quote.Node.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, quote.Node.String())
+		}
+	}
+
+	program := testParseProgram("macroexpand(1)")
+	_, errs := ExpandMacros(program, object.NewEnvironment())
+	if len(errs) != 1 || errs[0].Error() != "1:1: in expansion of macroexpand: macroexpand needs quoted code, as in macroexpand(quote(m(x)))" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestImportedMacros(t *testing.T) {
+	dir := t.TempDir()
+	src := `
+		let twice = macro(a) { quote(unquote(a) * 2) };
+		export let unless = macro(cond, cons) { quote(if (!(unquote(cond))) { unquote(cons) }) };
+		export let ten = 10;
+	`
+	if err := os.WriteFile(filepath.Join(dir, "control.monkey"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	previous := DefaultImporter
+	DefaultImporter = NewImporter(dir)
+	defer func() { DefaultImporter = previous }()
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let c = import "control"; c["unless"](false, c["ten"])`, 10},
+		{`let c = import "control"; c.unless(false, c.ten)`, 10},
+		{`let f = fn() { let c = import "control"; c["unless"](1 > 2, 3) }; f()`, 3},
+		// only exported macros are visible
+		{`let c = import "control"; c["twice"](1)`, "module control does not export twice"},
+	}
+	for _, tt := range tests {
+		evaluated := testExpandEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%q: expected error %q, got %v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestMacroHygienePatterns(t *testing.T) {
+	input := `
+	let first = macro(h) { quote(if (true) { let {name} = unquote(h); let [x, ...rest] = [1, 2]; name }) };
+	let name = "outer";
+	let x = "x";
+	first({"name": "inner"}) + name + x`
+	evaluated := testExpandEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok || str.Value != "innerouterx" {
+		t.Fatalf("expected %q, got %v", "innerouterx", evaluated)
+	}
+	// a match arm binding must not capture the caller's variable either
+	input = `
+	let plusOne = macro(e) { quote(match (1) { x => unquote(e) + x }) };
+	let x = 10;
+	plusOne(x)`
+	testIntegerObject(t, testExpandEval(input), 11)
+}
@@ -0,0 +1,249 @@
+package evaluator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// methods holds the methods of each type, called as value.name(args). The Params and
+// Signature of a method describe the arguments after the value, which its Fn is passed first.
+var methods = map[object.ObjectType]map[string]*object.Builtin{}
+
+// RegisterMethod makes method callable on every value of type t as value.name(args)
+func RegisterMethod(t object.ObjectType, name string, method *object.Builtin) {
+	if methods[t] == nil {
+		methods[t] = make(map[string]*object.Builtin)
+	}
+	methods[t][name] = method
+}
+
+// LookupMethod returns the method called name of the values of type t
+func LookupMethod(t object.ObjectType, name string) (*object.Builtin, bool) {
+	method, ok := methods[t][name]
+	return method, ok
+}
+
+// lookupMethod returns what receiver.name(args) calls: a method of the type of receiver,
+// or else a function stored in a field of a hash or exported by a module
+func lookupMethod(receiver object.Object, name string) object.Object {
+	if method, ok := LookupMethod(receiver.Type(), name); ok {
+		return &object.Builtin{
+			Signature: method.Signature,
+			Doc:       method.Doc,
+			Params:    method.Params,
+			Variadic:  method.Variadic,
+			Fn: func(args ...object.Object) object.Object {
+				if msg := method.CheckArity(len(args)); msg != "" {
+					return newError("wrong number of arguments to %s: %s", name, msg)
+				}
+				return method.Fn(append([]object.Object{receiver}, args...)...)
+			},
+		}
+	}
+	switch receiver := receiver.(type) {
+	case *object.Hash:
+		if pair, ok := receiver.Pairs[(&object.String{Value: name}).HashKey()]; ok {
+			return pair.Value
+		}
+	case *object.Module:
+		return evalModuleIndexExpression(receiver, &object.String{Value: name})
+	}
+	return newError("undefined method %s for %s", name, receiver.Type())
+}
+
+// evalMemberExpression reads h.key as h["key"] and m.name as m["name"]
+func evalMemberExpression(left object.Object, name string) object.Object {
+	switch left.Type() {
+	case object.HASH_OBJ:
+		return evalHashIndexExpression(left, &object.String{Value: name})
+	case object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, &object.String{Value: name})
+	default:
+		return newError("cannot read the field %s of %s", name, left.Type())
+	}
+}
+
+func init() {
+	for _, name := range []string{"len", "first", "last", "rest"} {
+		builtin := builtins[name]
+		RegisterMethod(object.ARRAY_OBJ, name, &object.Builtin{
+			Signature: "array." + name + "()",
+			Doc:       builtin.Doc,
+			Fn:        builtin.Fn,
+		})
+	}
+	RegisterMethod(object.ARRAY_OBJ, "push", &object.Builtin{
+		Signature: "array.push(value)",
+		Doc:       builtins["push"].Doc,
+		Params:    []object.BuiltinParam{{Name: "value"}},
+		Fn:        builtins["push"].Fn,
+	})
+	RegisterMethod(object.ARRAY_OBJ, "map", &object.Builtin{
+		Signature: "array.map(f)",
+		Doc:       "Returns a new ARRAY holding the result of calling f with each element.",
+		Params:    []object.BuiltinParam{{Name: "f", Types: []object.ObjectType{object.FUNCTION_OBJ, object.BUILTIN_OBJ}}},
+		Fn: func(args ...object.Object) object.Object {
+			elements := args[0].(*object.Array).Elements
+			mapped := make([]object.Object, len(elements))
+			for i, el := range elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				mapped[i] = result
+			}
+			return &object.Array{Elements: mapped}
+		},
+	})
+	RegisterMethod(object.ARRAY_OBJ, "filter", &object.Builtin{
+		Signature: "array.filter(f)",
+		Doc:       "Returns a new ARRAY holding the elements for which f returns a truthy value.",
+		Params:    []object.BuiltinParam{{Name: "f", Types: []object.ObjectType{object.FUNCTION_OBJ, object.BUILTIN_OBJ}}},
+		Fn: func(args ...object.Object) object.Object {
+			kept := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				result := applyFunction(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					kept = append(kept, el)
+				}
+			}
+			return &object.Array{Elements: kept}
+		},
+	})
+	RegisterMethod(object.ARRAY_OBJ, "reduce", &object.Builtin{
+		Signature: "array.reduce(f, initial)",
+		Doc:       "Calls f with the result so far and each element in turn, starting from initial, and returns the last result.",
+		Params: []object.BuiltinParam{
+			{Name: "f", Types: []object.ObjectType{object.FUNCTION_OBJ, object.BUILTIN_OBJ}},
+			{Name: "initial"},
+		},
+		Fn: func(args ...object.Object) object.Object {
+			result := args[2]
+			for _, el := range args[0].(*object.Array).Elements {
+				result = applyFunction(args[1], []object.Object{result, el})
+				if isError(result) {
+					return result
+				}
+			}
+			return result
+		},
+	})
+	RegisterMethod(object.ARRAY_OBJ, "join", &object.Builtin{
+		Signature: "array.join(separator)",
+		Doc:       "Returns the elements of an ARRAY of STRINGs joined by separator.",
+		Params:    []object.BuiltinParam{{Name: "separator", Types: []object.ObjectType{object.STRING_OBJ}}},
+		Fn: func(args ...object.Object) object.Object {
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `join` must be STRING, got %s", args[1].Type())
+			}
+			parts := []string{}
+			for _, el := range args[0].(*object.Array).Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError("cannot join an ARRAY holding %s", el.Type())
+				}
+				parts = append(parts, str.Value)
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	})
+
+	RegisterMethod(object.STRING_OBJ, "len", &object.Builtin{
+		Signature: "string.len()",
+		Doc:       builtins["len"].Doc,
+		Fn:        builtins["len"].Fn,
+	})
+	for _, m := range []struct {
+		name, doc string
+		convert   func(string) string
+	}{
+		{"upper", "in upper case", strings.ToUpper},
+		{"lower", "in lower case", strings.ToLower},
+		{"trim", "without leading and trailing white space", strings.TrimSpace},
+	} {
+		convert := m.convert
+		RegisterMethod(object.STRING_OBJ, m.name, &object.Builtin{
+			Signature: "string." + m.name + "()",
+			Doc:       "Returns a new STRING " + m.doc + ".",
+			Fn: func(args ...object.Object) object.Object {
+				return &object.String{Value: convert(args[0].(*object.String).Value)}
+			},
+		})
+	}
+	RegisterMethod(object.STRING_OBJ, "split", &object.Builtin{
+		Signature: "string.split(separator)",
+		Doc:       "Returns an ARRAY of the parts of a STRING between each separator.",
+		Params:    []object.BuiltinParam{{Name: "separator", Types: []object.ObjectType{object.STRING_OBJ}}},
+		Fn: func(args ...object.Object) object.Object {
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `split` must be STRING, got %s", args[1].Type())
+			}
+			parts := []object.Object{}
+			for _, part := range strings.Split(args[0].(*object.String).Value, sep.Value) {
+				parts = append(parts, &object.String{Value: part})
+			}
+			return &object.Array{Elements: parts}
+		},
+	})
+	RegisterMethod(object.STRING_OBJ, "contains", &object.Builtin{
+		Signature: "string.contains(substring)",
+		Doc:       "Reports whether substring occurs in a STRING.",
+		Params:    []object.BuiltinParam{{Name: "substring", Types: []object.ObjectType{object.STRING_OBJ}}},
+		Fn: func(args ...object.Object) object.Object {
+			sub, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `contains` must be STRING, got %s", args[1].Type())
+			}
+			return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, sub.Value))
+		},
+	})
+
+	RegisterMethod(object.HASH_OBJ, "keys", &object.Builtin{
+		Signature: "hash.keys()",
+		Doc:       "Returns an ARRAY of the keys of a HASH, sorted by how they print.",
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Array{Elements: sortedPairs(args[0].(*object.Hash), func(pair object.HashPair) object.Object { return pair.Key })}
+		},
+	})
+	RegisterMethod(object.HASH_OBJ, "values", &object.Builtin{
+		Signature: "hash.values()",
+		Doc:       "Returns an ARRAY of the values of a HASH, in the order of keys().",
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Array{Elements: sortedPairs(args[0].(*object.Hash), func(pair object.HashPair) object.Object { return pair.Value })}
+		},
+	})
+	RegisterMethod(object.HASH_OBJ, "has", &object.Builtin{
+		Signature: "hash.has(key)",
+		Doc:       "Reports whether a HASH holds key.",
+		Params:    []object.BuiltinParam{{Name: "key"}},
+		Fn: func(args ...object.Object) object.Object {
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			_, found := args[0].(*object.Hash).Pairs[key.HashKey()]
+			return nativeBoolToBooleanObject(found)
+		},
+	})
+}
+
+// sortedPairs returns part of each pair of hash, ordered by how the keys print
+func sortedPairs(hash *object.Hash, part func(object.HashPair) object.Object) []object.Object {
+	pairs := make([]object.HashPair, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key.Inspect() < pairs[j].Key.Inspect() })
+	parts := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		parts[i] = part(pair)
+	}
+	return parts
+}
@@ -0,0 +1,195 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/object"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+// ModuleExtension is appended to import paths that do not name an extension
+const ModuleExtension = ".monkey"
+
+// Importer resolves import paths, evaluates each module once in its own environment
+// and caches the resulting namespace so later imports share the same bindings.
+type Importer struct {
+	SearchPath []string // directories searched for non-relative import paths, in order
+
+	modules map[string]*object.Module // keyed by absolute file path
+	macros  map[string]*object.Module // the macros of each file, keyed like modules
+	loading []string                  // modules currently being evaluated, innermost last
+}
+
+func NewImporter(searchPath ...string) *Importer {
+	return &Importer{
+		SearchPath: searchPath,
+		modules:    make(map[string]*object.Module),
+		macros:     make(map[string]*object.Module),
+	}
+}
+
+// DefaultImporter is used by Eval to satisfy import expressions
+var DefaultImporter = NewImporter(".")
+
+// Import returns the module found at path, loading it on first use. Paths starting
+// with "./" or "../" are relative to the importing module, everything else is looked
+// up on the search path.
+func (im *Importer) Import(path string) object.Object {
+	file, err := im.resolve(path)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if mod, ok := im.modules[file]; ok {
+		return mod
+	}
+	for i, loading := range im.loading {
+		if loading == file {
+			cycle := []string{}
+			for _, f := range append(im.loading[i:], file) {
+				cycle = append(cycle, moduleName(f))
+			}
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	return im.load(file)
+}
+
+func (im *Importer) load(file string) object.Object {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return newError("cannot read module %s: %s", moduleName(file), err)
+	}
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("parse errors in module %s: %s", moduleName(file), strings.Join(p.Errors(), "; "))
+	}
+
+	im.loading = append(im.loading, file)
+	defer func() { im.loading = im.loading[:len(im.loading)-1] }()
+
+	// every module gets its own environments, so nothing leaks between files
+	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, macroErrs := ExpandMacros(program, macroEnv)
+	if len(macroErrs) != 0 {
+		return newError("in module %s: %s", moduleName(file), macroErrs[0])
+	}
+	if result := Eval(expanded, env); isError(result) {
+		return newError("in module %s: %s", moduleName(file), result.(*object.Error).Message)
+	}
+
+	mod := &object.Module{Name: moduleName(file), Path: file, Exports: make(map[string]object.Object)}
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		for _, name := range letNames(export.Statement) {
+			if val, ok := env.Get(name.Value); ok {
+				mod.Exports[name.Value] = val
+			}
+		}
+	}
+	im.modules[file] = mod
+	return mod
+}
+
+// ImportMacros returns the module found at path with only its exported macros filled in.
+// The module is not evaluated, so this can be done while expanding the importing code.
+func (im *Importer) ImportMacros(path string) (*object.Module, error) {
+	file, err := im.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if mod, ok := im.macros[file]; ok {
+		return mod, nil
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read module %s: %s", moduleName(file), err)
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse errors in module %s: %s", moduleName(file), strings.Join(p.Errors(), "; "))
+	}
+
+	exported := []string{}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok && isMacroDefinition(export) {
+			exported = append(exported, export.Statement.Name.Value)
+		}
+	}
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	mod := &object.Module{Name: moduleName(file), Path: file, Macros: make(map[string]*object.Macro)}
+	for _, name := range exported {
+		macro, _ := env.Get(name)
+		mod.Macros[name] = macro.(*object.Macro)
+	}
+	im.macros[file] = mod
+	return mod, nil
+}
+
+func (im *Importer) resolve(path string) (string, error) {
+	// a dot may be part of a module name, as in "utils.v2"
+	if !strings.HasSuffix(path, ModuleExtension) {
+		path += ModuleExtension
+	}
+
+	var candidates []string
+	switch {
+	case filepath.IsAbs(path):
+		candidates = []string{path}
+	case strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../"):
+		dir := "."
+		if len(im.loading) > 0 {
+			dir = filepath.Dir(im.loading[len(im.loading)-1])
+		}
+		candidates = []string{filepath.Join(dir, path)}
+	default:
+		for _, dir := range im.SearchPath {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return filepath.Abs(candidate)
+		}
+	}
+	return "", fmt.Errorf("module not found: %s", path)
+}
+
+// letNames returns the names bound by a let statement
+func letNames(let *ast.LetStatement) []*ast.Identifier {
+	if let.Pattern != nil {
+		return ast.PatternIdentifiers(let.Pattern)
+	}
+	return []*ast.Identifier{let.Name}
+}
+
+func moduleName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	moduleObject := module.(*object.Module)
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module index must be STRING, got %s", index.Type())
+	}
+	val, ok := moduleObject.Exports[name.Value]
+	if !ok {
+		return newError("module %s does not export %s", moduleObject.Name, name.Value)
+	}
+	return val
+}
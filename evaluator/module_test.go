@@ -0,0 +1,116 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// testImport writes the given files into a temporary search path and evaluates input against it
+func testImport(t *testing.T, files map[string]string, input string) object.Object {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := DefaultImporter
+	DefaultImporter = NewImporter(dir)
+	defer func() { DefaultImporter = previous }()
+	return testEval(input)
+}
+
+func TestImportExports(t *testing.T) {
+	files := map[string]string{
+		"math.monkey": `
+			let square = fn(x) { x * x };
+			export let double = fn(x) { x * 2 };
+			export let ten = square(3) + 1;
+			export let [one, {two}] = [1, {"two": 2}];
+		`,
+		"utils.v2.monkey": `export let version = 2;`,
+		"lib/strings.monkey": `
+			let unless = macro(cond, cons) { quote(if (!(unquote(cond))) { unquote(cons) }) };
+			export let greet = fn(name) { unless(false, "hello " + name) };
+		`,
+	}
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import "math"; m["double"](4)`, 8},
+		{`let m = import "math.monkey"; m["ten"]`, 10},
+		{`let s = import "lib/strings"; s["greet"]("monkey")`, "hello monkey"},
+		{`let m = import "math"; m["one"] + m["two"]`, 3},
+		{`let m = import "math"; m["square"]`, "module math does not export square"},
+		{`let m = import "math"; m.double(m.ten) + m.one`, 21},
+		{`let m = import "math"; m.square(2)`, "module math does not export square"},
+		{`let u = import "utils.v2"; u.version`, 2},
+		{`let u = import "utils.v2.monkey"; u.version`, 2},
+		{`import "nope"`, "module not found: nope.monkey"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testImport(t, files, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object. got=%[1]T (%[1]+v)", evaluated)
+			}
+		}
+	}
+}
+
+func TestImportIsCached(t *testing.T) {
+	files := map[string]string{
+		"counter.monkey": `export let value = [];`,
+	}
+	evaluated := testImport(t, files, `
+		let a = import "counter";
+		let b = import "counter";
+		a["value"] == b["value"];
+	`)
+	testBooleanObject(t, evaluated, true)
+}
+
+func TestImportRelativePaths(t *testing.T) {
+	files := map[string]string{
+		"app/main.monkey":   `let helper = import "./helper"; export let answer = helper["value"] + 1;`,
+		"app/helper.monkey": `export let value = 41;`,
+	}
+	evaluated := testImport(t, files, `let app = import "app/main"; app["answer"]`)
+	testIntegerObject(t, evaluated, 42)
+}
+
+func TestImportCycle(t *testing.T) {
+	files := map[string]string{
+		"a.monkey": `let b = import "b"; export let x = 1;`,
+		"b.monkey": `let a = import "a"; export let y = 2;`,
+	}
+	evaluated := testImport(t, files, `import "a"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%[1]T (%[1]+v)", evaluated)
+	}
+	expected := "in module a: in module b: import cycle: a -> b -> a"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/josh-weston/go_interpreter/ast"
@@ -8,26 +9,163 @@ import (
 	"github.com/josh-weston/go_interpreter/token"
 )
 
+// quote returns node with its unquote calls evaluated. node is copied first, since it is
+// part of the program or of a macro body which may be evaluated again.
 func quote(node ast.Node, env *object.Environment) object.Object {
-	node = evalUnquoteCalls(node, env)
+	node, err := evalUnquoteCalls(ast.Clone(node), env)
+	if err != nil {
+		return newError("unquote: %s", err)
+	}
 	return &object.Quote{Node: node}
 }
 
-func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
-	return ast.Modify(quoted, func(node ast.Node) ast.Node { // call our Modify function with a modifier function
-		if !isUnquoteCall(node) {
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, error) {
+	var convertErr error
+	spliced := map[*ast.CallExpression]bool{}
+	modified, err := ast.Modify(quoted, func(node ast.Node) ast.Node { // call our Modify function with a modifier function
+		if convertErr != nil {
 			return node
 		}
-		call, ok := node.(*ast.CallExpression)
-		if !ok {
-			return node
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			if isUnquoteCall(node) {
+				return unquote(node, env, &convertErr)
+			}
+			node.Arguments = spliceExpressions(node.Arguments, env, spliced, &convertErr)
+		case *ast.ArrayLiteral:
+			node.Elements = spliceExpressions(node.Elements, env, spliced, &convertErr)
+		case *ast.BlockStatement:
+			node.Statements = spliceStatements(node.Statements, env, spliced, &convertErr)
+		case *ast.Program:
+			node.Statements = spliceStatements(node.Statements, env, spliced, &convertErr)
 		}
-		if len(call.Arguments) != 1 {
-			return node
+		return node
+	})
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	if err != nil {
+		return modified, err
+	}
+	// the splices that were not replaced are somewhere a list of nodes cannot go
+	ast.Inspect(modified, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && isUnquoteSpliceCall(call) && !spliced[call] {
+			convertErr = errors.New("unquote_splice can only be used in argument lists, array literals and blocks")
 		}
-		unquoted := Eval(call.Arguments[0], env)
-		return convertObjectToASTNode(unquoted)
+		return convertErr == nil
 	})
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	return modified, nil
+}
+
+func unquote(call *ast.CallExpression, env *object.Environment, errp *error) ast.Node {
+	if len(call.Arguments) != 1 {
+		return call
+	}
+	unquoted := Eval(call.Arguments[0], env)
+	if err, ok := unquoted.(*object.Error); ok {
+		*errp = errors.New(err.Message)
+		return call
+	}
+	converted, err := convertObjectToASTNode(unquoted)
+	if err != nil {
+		*errp = err
+		return call
+	}
+	return converted
+}
+
+// splice evaluates the argument of an unquote_splice call and returns the code for each
+// element of the array it gives
+func splice(call *ast.CallExpression, env *object.Environment) ([]ast.Node, error) {
+	if len(call.Arguments) != 1 {
+		return nil, fmt.Errorf("unquote_splice takes 1 argument, got %d", len(call.Arguments))
+	}
+	evaluated := Eval(call.Arguments[0], env)
+	if err, ok := evaluated.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		if evaluated == nil {
+			return nil, errors.New("unquote_splice needs an ARRAY, got nothing")
+		}
+		return nil, fmt.Errorf("unquote_splice needs an ARRAY, got %s", evaluated.Type())
+	}
+	nodes := make([]ast.Node, 0, len(array.Elements))
+	for _, el := range array.Elements {
+		node, err := convertObjectToASTNode(el)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// spliceExpressions replaces the unquote_splice calls in exps by the expressions they give
+func spliceExpressions(exps []ast.Expression, env *object.Environment, spliced map[*ast.CallExpression]bool, errp *error) []ast.Expression {
+	result := make([]ast.Expression, 0, len(exps))
+	for _, exp := range exps {
+		call, ok := exp.(*ast.CallExpression)
+		if !ok || !isUnquoteSpliceCall(call) {
+			result = append(result, exp)
+			continue
+		}
+		spliced[call] = true
+		nodes, err := splice(call, env)
+		if err != nil {
+			*errp = err
+			return exps
+		}
+		for _, node := range nodes {
+			exp, ok := node.(ast.Expression)
+			if !ok {
+				*errp = fmt.Errorf("unquote_splice: cannot use %q as an expression", node.String())
+				return exps
+			}
+			result = append(result, exp)
+		}
+	}
+	return result
+}
+
+// spliceStatements replaces the statements consisting of an unquote_splice call by a
+// statement for each node it gives
+func spliceStatements(statements []ast.Statement, env *object.Environment, spliced map[*ast.CallExpression]bool, errp *error) []ast.Statement {
+	result := make([]ast.Statement, 0, len(statements))
+	for _, statement := range statements {
+		stmt, ok := statement.(*ast.ExpressionStatement)
+		if !ok {
+			result = append(result, statement)
+			continue
+		}
+		call, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok || !isUnquoteSpliceCall(call) {
+			result = append(result, statement)
+			continue
+		}
+		spliced[call] = true
+		nodes, err := splice(call, env)
+		if err != nil {
+			*errp = err
+			return statements
+		}
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case ast.Statement:
+				result = append(result, node)
+			case ast.Expression:
+				result = append(result, &ast.ExpressionStatement{Token: stmt.Token, Expression: node})
+			default:
+				*errp = fmt.Errorf("unquote_splice: cannot use %q as a statement", node.String())
+				return statements
+			}
+		}
+	}
+	return result
 }
 
 func isUnquoteCall(node ast.Node) bool {
@@ -38,14 +176,26 @@ func isUnquoteCall(node ast.Node) bool {
 	return callExpression.Function.TokenLiteral() == "unquote"
 }
 
-func convertObjectToASTNode(obj object.Object) ast.Node {
+func isUnquoteSpliceCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	return callExpression.Function.TokenLiteral() == "unquote_splice"
+}
+
+// convertObjectToASTNode returns an expression that evaluates to obj
+func convertObjectToASTNode(obj object.Object) (ast.Node, error) {
 	switch obj := obj.(type) {
 	case *object.Integer:
 		t := token.Token{
 			Type:    token.INT,
 			Literal: fmt.Sprintf("%d", obj.Value),
 		}
-		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: ast.FormatFloat(obj.Value)}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, nil
 	case *object.Boolean:
 		var t token.Token
 		if obj.Value {
@@ -53,10 +203,39 @@ func convertObjectToASTNode(obj object.Object) ast.Node {
 		} else {
 			t = token.Token{Type: token.FALSE, Literal: "false"}
 		}
-		return &ast.Boolean{Token: t, Value: obj.Value}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value}, Value: obj.Value}, nil
+	case *object.Null:
+		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+		for _, el := range obj.Elements {
+			node, err := convertObjectToASTNode(el)
+			if err != nil {
+				return nil, err
+			}
+			array.Elements = append(array.Elements, node.(ast.Expression))
+		}
+		return array, nil
+	case *object.Hash:
+		hash := &ast.HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Pairs: make(map[ast.Expression]ast.Expression)}
+		for _, pair := range obj.Pairs {
+			key, err := convertObjectToASTNode(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := convertObjectToASTNode(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			hash.Pairs[key.(ast.Expression)] = value.(ast.Expression)
+		}
+		return hash, nil
 	case *object.Quote:
-		return obj.Node
+		// the same quoted node may be unquoted in several places
+		return ast.Clone(obj.Node), nil
 	default:
-		return nil
+		return nil, fmt.Errorf("a %s cannot be converted to code", obj.Type())
 	}
 }
@@ -66,6 +66,10 @@ func TestQuoteUnquote(t *testing.T) {
 			`quote(unquote(4 + 4) + 8)`,
 			`(8 + 8)`,
 		},
+		{
+			`quote(f(unquote(4 + 4), [unquote(1 + 1)]))`,
+			`f(8, [2])`,
+		},
 		{
 			`let foobar = 8;
 			quote(foobar);`,
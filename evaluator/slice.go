@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// evalSliceExpression takes the elements of an array or the characters of a string from
+// start up to but not including end, every step apart. Negative bounds count from the end
+// and bounds past either end are clamped, so a slice is never out of range. A negative
+// step walks backwards from the end, s[::-1] reversing s.
+func evalSliceExpression(node *ast.SliceExpression, left object.Object, env *object.Environment) object.Object {
+	var length int
+	var chars []rune // the characters of a string, which are sliced rather than its bytes
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		chars = []rune(left.Value)
+		length = len(chars)
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	bounds := make([]*int64, 3)
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		val := Eval(exp, env)
+		if isError(val) {
+			return val
+		}
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice bounds must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return newError("slice step cannot be zero")
+	}
+	indices := sliceIndices(bounds[0], bounds[1], step, int64(length))
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(indices))
+		for i, idx := range indices {
+			elements[i] = left.Elements[idx]
+		}
+		return &object.Array{Elements: elements}
+	default:
+		var sb strings.Builder
+		for _, idx := range indices {
+			sb.WriteRune(chars[idx])
+		}
+		return &object.String{Value: sb.String()}
+	}
+}
+
+// sliceIndices returns the positions a slice takes from a sequence of length elements.
+// start and end are nil where they were left out.
+func sliceIndices(start, end *int64, step, length int64) []int64 {
+	// the first and last position a walk may reach, and where it starts and stops by default
+	lower, upper := int64(0), length
+	from, to := lower, upper
+	if step < 0 {
+		lower, upper = -1, length-1
+		from, to = upper, lower
+	}
+	clamp := func(bound *int64, def int64) int64 {
+		if bound == nil {
+			return def
+		}
+		idx := *bound
+		if idx < 0 {
+			idx += length
+		}
+		if idx < lower {
+			return lower
+		}
+		if idx > upper {
+			return upper
+		}
+		return idx
+	}
+	from, to = clamp(start, from), clamp(end, to)
+
+	indices := []int64{}
+	for i := from; (step > 0 && i < to) || (step < 0 && i > to); i += step {
+		indices = append(indices, i)
+	}
+	return indices
+}
@@ -0,0 +1,30 @@
+package format
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	expected := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if got := Diff("a", "b", []byte(a), []byte(b)); got != expected {
+		t.Errorf("Diff wrong.\nwant=%q\ngot= %q", expected, got)
+	}
+	if got := Diff("a", "b", []byte(a), []byte(a)); got != "" {
+		t.Errorf("Diff of equal input should be empty. got=%q", got)
+	}
+}
@@ -0,0 +1,586 @@
+// Package format prints Monkey programs in their canonical layout: tab indentation, one
+// statement per line, only the parentheses the parser needs, and comments kept in place.
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+// Source formats a whole file. Formatting already formatted source returns it unchanged.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s", strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := &printer{comments: l.Comments(), closers: lexer.MatchDelimiters(string(src))}
+	pr.program(program)
+	return []byte(pr.sb.String()), nil
+}
+
+// Node formats a single node. Without the source there are no comments, and layout
+// that depends on the original line breaks falls back to the defaults.
+func Node(node ast.Node) string {
+	pr := &printer{closers: map[int]token.Position{}}
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.program(node)
+		return strings.TrimSuffix(pr.sb.String(), "\n")
+	case ast.Statement:
+		pr.statement(node, true, nil)
+	case ast.Expression:
+		pr.expr(node, parser.LOWEST)
+	}
+	return pr.sb.String()
+}
+
+// eof sorts after every comment, so using it as the next position flushes them all
+var eof = token.Position{Offset: math.MaxInt32, Line: math.MaxInt32}
+
+type printer struct {
+	sb       strings.Builder
+	indent   int
+	comments []token.Comment
+	next     int                    // index of the first comment not printed yet
+	lastLine int                    // last source line printed so far
+	closers  map[int]token.Position // offset of '(', '[' or '{' -> position of the matching closer
+}
+
+func (p *printer) write(s string) {
+	p.sb.WriteString(s)
+}
+
+// seen records that the source at pos has been printed
+func (p *printer) seen(pos token.Position) {
+	if pos.IsValid() && pos.Line > p.lastLine {
+		p.lastLine = pos.Line
+	}
+}
+
+func (p *printer) pending(next token.Position) bool {
+	return p.next < len(p.comments) && next.IsValid() && p.comments[p.next].Pos.Offset < next.Offset
+}
+
+// trailing prints the comments that share the source line just printed
+func (p *printer) trailing(next token.Position) {
+	for p.pending(next) && p.comments[p.next].Pos.Line == p.lastLine {
+		p.write(" " + p.comments[p.next].Text)
+		p.next++
+	}
+}
+
+// ownLine prints the remaining comments before next, each on its own line
+func (p *printer) ownLine(next token.Position, blank bool) {
+	for p.pending(next) {
+		c := p.comments[p.next]
+		p.newline()
+		if blank && p.sb.Len() > 0 && c.Pos.Line > p.lastLine+1 {
+			p.write("\n")
+		}
+		p.writeIndent()
+		p.write(c.Text)
+		p.seen(c.Pos)
+		p.next++
+	}
+}
+
+func (p *printer) newline() {
+	if p.sb.Len() > 0 {
+		p.write("\n")
+	}
+}
+
+func (p *printer) writeIndent() {
+	p.write(strings.Repeat("\t", p.indent))
+}
+
+// linebreak ends the current line and starts the one holding the node at next, printing
+// any comments in between. blank keeps a single empty line where the source had any.
+func (p *printer) linebreak(next token.Position, blank bool) {
+	p.trailing(next)
+	p.ownLine(next, blank)
+	p.newline()
+	if blank && p.sb.Len() > 0 && next.IsValid() && next.Line > p.lastLine+1 {
+		p.write("\n")
+	}
+	p.writeIndent()
+}
+
+func (p *printer) program(program *ast.Program) {
+	for i, stmt := range program.Statements {
+		p.linebreak(stmt.Pos(), true)
+		var next ast.Statement
+		if i+1 < len(program.Statements) {
+			next = program.Statements[i+1]
+		}
+		p.statement(stmt, false, next)
+	}
+	p.trailing(eof)
+	p.ownLine(eof, true)
+	p.newline()
+}
+
+// statement prints stmt and its semicolon. The semicolon is left off the final
+// statement of a block and after an if that cannot be mistaken for the left side
+// of whatever follows it.
+func (p *printer) statement(stmt ast.Statement, lastInBlock bool, next ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.seen(stmt.Token.Pos)
+		p.write("let ")
+		if stmt.Pattern != nil {
+			p.expr(stmt.Pattern, parser.LOWEST)
+		} else {
+			p.write(stmt.Name.Value)
+		}
+		p.write(" = ")
+		p.expr(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.seen(stmt.Token.Pos)
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expr(stmt.ReturnValue, parser.LOWEST)
+		}
+		p.write(";")
+	case *ast.ExportStatement:
+		p.seen(stmt.Token.Pos)
+		p.write("export ")
+		p.statement(stmt.Statement, lastInBlock, next)
+	case *ast.ExpressionStatement:
+		p.expr(stmt.Expression, parser.LOWEST)
+		if lastInBlock {
+			return
+		}
+		if _, ok := stmt.Expression.(*ast.IfExpression); ok && !continuesExpression(next) {
+			return
+		}
+		p.write(";")
+	}
+}
+
+// continuesExpression reports whether stmt will be printed starting with a token that
+// would otherwise be read as an infix operator applied to the previous expression
+func continuesExpression(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok || es.Expression == nil {
+		return false
+	}
+	switch firstToken(es.Expression, parser.LOWEST) {
+	case "(", "[", "-":
+		return true
+	}
+	return false
+}
+
+// firstToken returns how the printed form of e starts, following the same rules as expr
+func firstToken(e ast.Expression, prec int) string {
+	if precedence(e) < prec {
+		return "("
+	}
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return firstToken(e.Left, parser.Precedence(token.TokenType(e.Operator)))
+	case *ast.CallExpression:
+		return firstToken(e.Function, parser.CALL)
+	case *ast.IndexExpression:
+		return firstToken(e.Left, parser.CALL)
+	case *ast.SliceExpression:
+		return firstToken(e.Left, parser.CALL)
+	case *ast.MemberExpression:
+		return firstToken(e.Object, parser.CALL)
+	case *ast.PrefixExpression:
+		return e.Operator
+	case *ast.ArrayLiteral:
+		return "["
+	}
+	return e.TokenLiteral()
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	p.seen(b.Token.Pos)
+	closer, hasCloser := p.closers[b.Token.Pos.Offset]
+	if !b.Token.Pos.IsValid() {
+		closer, hasCloser = token.Position{}, false
+	}
+
+	if len(b.Statements) == 0 && !p.pending(closer) {
+		p.write("{}")
+		p.seen(closer)
+		return
+	}
+	if hasCloser && p.fitsOnOneLine(b, closer) {
+		p.write("{ ")
+		p.statement(b.Statements[0], true, nil)
+		p.write(" }")
+		p.seen(closer)
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	for i, stmt := range b.Statements {
+		p.linebreak(stmt.Pos(), i > 0)
+		p.statement(stmt, i == len(b.Statements)-1, nil)
+	}
+	p.trailing(closer)
+	p.ownLine(closer, true)
+	p.indent--
+	p.write("\n")
+	p.writeIndent()
+	p.write("}")
+	p.seen(closer)
+}
+
+// fitsOnOneLine keeps short blocks like `fn(x) { x * 2 }` on one line when they were written
+// that way, and when nothing inside the statement is printed over several lines
+func (p *printer) fitsOnOneLine(b *ast.BlockStatement, closer token.Position) bool {
+	if len(b.Statements) != 1 || b.Token.Pos.Line != closer.Line || p.pending(closer) {
+		return false
+	}
+	switch b.Statements[0].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement:
+	default:
+		return false
+	}
+	for open, inner := range p.closers {
+		if open > b.Token.Pos.Offset && open < closer.Offset && inner.Line != b.Token.Pos.Line {
+			return false
+		}
+	}
+	// a nested block with several statements is broken even if the source had it on one line
+	trial := &printer{comments: p.comments, next: p.next, lastLine: p.lastLine, closers: p.closers}
+	trial.statement(b.Statements[0], true, nil)
+	return !strings.Contains(trial.sb.String(), "\n")
+}
+
+// precedence returns how tightly e binds, so operands with looser binding get parentheses
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.TokenType(e.Operator))
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	default:
+		return parser.INDEX
+	}
+}
+
+func (p *printer) expr(e ast.Expression, prec int) {
+	if e == nil {
+		return
+	}
+	if precedence(e) < prec {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.seen(e.Token.Pos)
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.seen(e.Token.Pos)
+		p.write(strconv.FormatInt(e.Value, 10))
+	case *ast.FloatLiteral:
+		p.seen(e.Token.Pos)
+		p.write(ast.FormatFloat(e.Value))
+	case *ast.StringLiteral:
+		p.seen(e.Token.Pos)
+		p.write(`"` + e.Value + `"`)
+	case *ast.InterpolatedString:
+		p.seen(e.Token.Pos)
+		p.write(`"`)
+		for _, part := range e.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok {
+				p.write(text.Value)
+				continue
+			}
+			p.write("${")
+			p.expr(part, parser.LOWEST)
+			p.write("}")
+		}
+		p.write(`"`)
+	case *ast.Boolean:
+		p.seen(e.Token.Pos)
+		p.write(strconv.FormatBool(e.Value))
+	case *ast.NullLiteral:
+		p.seen(e.Token.Pos)
+		p.write("null")
+	case *ast.PrefixExpression:
+		p.seen(e.Token.Pos)
+		p.write(e.Operator)
+		p.expr(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		opPrec := parser.Precedence(token.TokenType(e.Operator))
+		p.expr(e.Left, opPrec)
+		p.write(" " + e.Operator + " ")
+		p.expr(e.Right, opPrec+1) // operators are left associative
+	case *ast.IfExpression:
+		p.seen(e.Token.Pos)
+		p.write("if (")
+		p.expr(e.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.seen(e.Token.Pos)
+		p.write("fn")
+		p.parameters(e.Parameters, e.Patterns, e.Defaults, e.Rest)
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.seen(e.Token.Pos)
+		p.write("macro")
+		p.parameters(e.Parameters, nil, nil, e.Rest)
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.expr(e.Function, parser.CALL)
+		if e.Optional {
+			p.write("?.")
+		}
+		p.list("(", e.Arguments, ")", e.Token.Pos)
+	case *ast.IndexExpression:
+		p.expr(e.Left, parser.CALL)
+		if e.Optional {
+			p.write("?.")
+		}
+		p.write("[")
+		p.expr(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.expr(e.Left, parser.CALL)
+		if e.Optional {
+			p.write("?.")
+		}
+		p.write("[")
+		p.expr(e.Start, parser.LOWEST)
+		p.write(":")
+		p.expr(e.End, parser.LOWEST)
+		if e.Step != nil {
+			p.write(":")
+			p.expr(e.Step, parser.LOWEST)
+		}
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expr(e.Object, parser.CALL)
+		if e.Optional {
+			p.write("?.")
+		} else {
+			p.write(".")
+		}
+		p.write(e.Property.Value)
+	case *ast.ArrayLiteral:
+		p.list("[", e.Elements, "]", e.Token.Pos)
+	case *ast.HashLiteral:
+		p.hash(e)
+	case *ast.ArrayPattern:
+		p.seen(e.Token.Pos)
+		p.write("[")
+		for i, el := range e.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(el, parser.LOWEST)
+		}
+		if e.Rest != nil {
+			if len(e.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write("..." + e.Rest.Value)
+		}
+		p.write("]")
+	case *ast.HashPattern:
+		p.seen(e.Token.Pos)
+		p.write("{")
+		for i, key := range e.Keys {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(key.Value)
+			if value := e.Value(i); value != nil {
+				p.write(": ")
+				p.expr(value, parser.LOWEST)
+			}
+		}
+		p.write("}")
+	case *ast.SpreadExpression:
+		p.seen(e.Token.Pos)
+		p.write("...")
+		p.expr(e.Value, parser.PREFIX)
+	case *ast.ImportExpression:
+		p.seen(e.Token.Pos)
+		p.write(`import "` + e.Path.Value + `"`)
+	case *ast.MatchExpression:
+		p.match(e)
+	}
+}
+
+// match prints the arms on one line if the first one starts on the line of the match,
+// and one per line otherwise
+func (p *printer) match(m *ast.MatchExpression) {
+	p.seen(m.Token.Pos)
+	p.write("match (")
+	p.expr(m.Subject, parser.LOWEST)
+	p.write(") {")
+	// the AST does not keep the braces, so find them among the delimiters that follow
+	closer, _ := lexer.BlockCloser(p.closers, m.Token.Pos.Offset)
+	broken := len(m.Arms) > 0 && p.broken(m.Token.Pos, m.Arms[0].Pattern.Pos())
+	if broken {
+		p.indent++
+	} else if len(m.Arms) > 0 {
+		p.write(" ")
+	}
+	for i, arm := range m.Arms {
+		if broken {
+			p.linebreak(arm.Pattern.Pos(), false)
+		} else if i > 0 {
+			p.write(", ")
+		}
+		p.expr(arm.Pattern, parser.LOWEST)
+		if arm.Guard != nil {
+			p.write(" if ")
+			p.expr(arm.Guard, parser.LOWEST)
+		}
+		p.write(" => ")
+		p.expr(arm.Body, parser.LOWEST)
+		if broken && i < len(m.Arms)-1 {
+			p.write(",")
+		}
+	}
+	if broken {
+		p.trailing(closer)
+		p.ownLine(closer, false)
+		p.indent--
+		p.write("\n")
+		p.writeIndent()
+	} else if len(m.Arms) > 0 {
+		p.write(" ")
+	}
+	p.write("}")
+	p.seen(closer)
+}
+
+func (p *printer) parameters(params []*ast.Identifier, patterns, defaults []ast.Expression, rest *ast.Identifier) {
+	p.write("(")
+	for i, param := range params {
+		if i > 0 {
+			p.write(", ")
+		}
+		if i < len(patterns) && patterns[i] != nil {
+			p.expr(patterns[i], parser.LOWEST)
+		} else {
+			p.write(param.Value)
+		}
+		if i < len(defaults) && defaults[i] != nil {
+			p.write(" = ")
+			p.expr(defaults[i], parser.LOWEST)
+		}
+	}
+	if rest != nil {
+		if len(params) > 0 {
+			p.write(", ")
+		}
+		p.write("..." + rest.Value)
+	}
+	p.write(") ")
+}
+
+// list prints comma separated expressions. If the source put the first element on a
+// new line, every element gets its own line.
+func (p *printer) list(open string, elements []ast.Expression, close string, openPos token.Position) {
+	p.seen(openPos)
+	closer := p.closers[openPos.Offset]
+	p.write(open)
+	if len(elements) == 0 || !p.broken(openPos, elements[0].Pos()) && !p.commented(openPos, closer) {
+		for i, el := range elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(el, parser.LOWEST)
+		}
+		p.write(close)
+		p.seen(closer)
+		return
+	}
+
+	p.indent++
+	for i, el := range elements {
+		p.linebreak(el.Pos(), false)
+		p.expr(el, parser.LOWEST)
+		if i < len(elements)-1 {
+			p.write(",")
+		}
+	}
+	p.trailing(closer)
+	p.ownLine(closer, false)
+	p.indent--
+	p.write("\n")
+	p.writeIndent()
+	p.write(close)
+	p.seen(closer)
+}
+
+func (p *printer) broken(open, first token.Position) bool {
+	return open.IsValid() && first.IsValid() && first.Line > open.Line
+}
+
+// commented reports whether a comment lies between a delimiter and its closer. Such a list
+// gets one element per line, so that a line comment doesn't swallow the rest of it.
+func (p *printer) commented(open, close token.Position) bool {
+	for _, c := range p.comments[p.next:] {
+		if c.Pos.Offset > open.Offset && close.IsValid() && c.Pos.Offset < close.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) hash(h *ast.HashLiteral) {
+	keys := h.Keys()
+
+	p.seen(h.Token.Pos)
+	closer := p.closers[h.Token.Pos.Offset]
+	p.write("{")
+	broken := len(keys) > 0 && (p.broken(h.Token.Pos, keys[0].Pos()) || p.commented(h.Token.Pos, closer))
+	if broken {
+		p.indent++
+	}
+	for i, key := range keys {
+		if broken {
+			p.linebreak(key.Pos(), false)
+		} else if i > 0 {
+			p.write(", ")
+		}
+		p.expr(key, parser.LOWEST)
+		p.write(": ")
+		p.expr(h.Pairs[key], parser.LOWEST)
+		if broken && i < len(keys)-1 {
+			p.write(",")
+		}
+	}
+	if broken {
+		p.trailing(closer)
+		p.ownLine(closer, false)
+		p.indent--
+		p.write("\n")
+		p.writeIndent()
+	}
+	p.write("}")
+	p.seen(closer)
+}
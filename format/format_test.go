@@ -0,0 +1,103 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2", "let x = 1 + 2;\n"},
+		{"(a + b) * c; a + (b * c); a - (b - c); (a - b) - c;", "(a + b) * c;\na + b * c;\na - (b - c);\na - b - c;\n"},
+		{"((-a) * b); -(a * b); !(!true); (-a)[0]; (a + b)(1)", "-a * b;\n-(a * b);\n!!true;\n(-a)[0];\n(a + b)(1);\n"},
+		{"f(1)(2)[0]; (fn(x){x})(5)", "f(1)(2)[0];\nfn(x) { x }(5);\n"},
+		{"let add = fn(a,b) {\nreturn a+b;\n}", "let add = fn(a, b) {\n\treturn a + b;\n};\n"},
+		{"if (x) { 1 } else { 2 }\nputs(x)", "if (x) { 1 } else { 2 }\nputs(x);\n"},
+		{"if (x) { 1 };\n(y)", "if (x) { 1 }\ny;\n"},
+		{"if (x) { 1 }\n-y", "if (x) { 1 } - y;\n"},
+		{"if (x) { 1 };\n-y", "if (x) { 1 };\n-y;\n"},
+		{"if (x) { 1 };\n[1, 2].len();", "if (x) { 1 };\n[1, 2].len();\n"},
+		{"if (x) { 1 };\n(-a).b", "if (x) { 1 };\n(-a).b;\n"},
+		{"if (x) { 1 };\n[1, 2][1:]", "if (x) { 1 };\n[1, 2][1:];\n"},
+		{"let e = fn() {   }", "let e = fn() {};\n"},
+		{`{"b": 1, "a": 2}`, "{\"b\": 1, \"a\": 2};\n"},
+		{"let a = [\n1,\n2]", "let a = [\n\t1,\n\t2\n];\n"},
+		{`export let m = import "lib"`, "export let m = import \"lib\";\n"},
+		{"let m = macro(a) { quote(unquote(a)) }", "let m = macro(a) { quote(unquote(a)) };\n"},
+		{"let m = macro(a,...args) { quote(f(unquote_splice(args))) }", "let m = macro(a, ...args) { quote(f(unquote_splice(args))) };\n"},
+		{"let f = fn(a,b=a*2,...rest) { f(...rest,b) }", "let f = fn(a, b = a * 2, ...rest) { f(...rest, b) };\n"},
+		{"let [a,[b],...r] = x; let {n, m:{k}} = y; fn([a], {b} = c) { a }", "let [a, [b], ...r] = x;\nlet {n, m: {k}} = y;\nfn([a], {b} = c) { a };\n"},
+		{"match(x){1=>a,[b,...c] if b>1=>c,_=>x}", "match (x) { 1 => a, [b, ...c] if b > 1 => c, _ => x };\n"},
+		{"match (x) {\n1 => a, // one\n_ => b\n}", "match (x) {\n\t1 => a, // one\n\t_ => b\n};\n"},
+		{"let p = (cfg?.[\"db\"]?.[\"port\"])??(5432); f?.( null )", "let p = cfg?.[\"db\"]?.[\"port\"] ?? 5432;\nf?.(null);\n"},
+		{"(h.a) ?. b.c( 1 )", "h.a?.b.c(1);\n"},
+		{"a[ 1 : 2 ];a[:(n)];a[::-1]", "a[1:2];\na[:n];\na[::-1];\n"},
+		{`"a ${ x+1 } b ${f( "${y}" )}"`, "\"a ${x + 1} b ${f(\"${y}\")}\";\n"},
+		{"let m = macro(x) { quote(fn([p, q]) {\n let t = p;\n t + q + unquote(x)\n }([1, 2])) };", "let m = macro(x) {\n\tquote(fn([p, q]) {\n\t\tlet t = p;\n\t\tt + q + unquote(x)\n\t}([1, 2]))\n};\n"},
+		{"let g = fn() { f(fn() { a; b }) }", "let g = fn() {\n\tf(fn() {\n\t\ta;\n\t\tb\n\t})\n};\n"},
+		{"let a = [1, // one\n 2];\nf(1, // x\n 2)", "let a = [\n\t1, // one\n\t2\n];\nf(\n\t1, // x\n\t2\n);\n"},
+		{"let h = {\"a\": 1, // one\n \"b\": 2}", "let h = {\n\t\"a\": 1, // one\n\t\"b\": 2\n};\n"},
+		{"let r = 2.50*(1.0)", "let r = 2.5 * 1.0;\n"},
+		// blank lines are kept but collapsed, and dropped at the start of a block
+		{"let a = 1;\n\n\n\nlet b = 2;", "let a = 1;\n\nlet b = 2;\n"},
+		{"fn() {\n\n  a;\n\n  b\n}", "fn() {\n\ta;\n\n\tb\n};\n"},
+		// comments
+		{"// head\nlet a = 1; // tail\n\n// own\nlet b = 2;", "// head\nlet a = 1; // tail\n\n// own\nlet b = 2;\n"},
+		{"fn() {\n  a; // x\n  // before close\n}", "fn() {\n\ta // x\n\t// before close\n};\n"},
+		{"let a = [\n  1, // one\n  2 // two\n];", "let a = [\n\t1, // one\n\t2 // two\n];\n"},
+		{"fn() { // why\n a }", "fn() { // why\n\ta\n};\n"},
+		{"// only a comment", "// only a comment\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant=%q\ngot= %q", tt.input, tt.expected, formatted)
+			continue
+		}
+
+		again, err := Source(formatted)
+		if err != nil || string(again) != string(formatted) {
+			t.Errorf("Source is not idempotent for %q. got=%q", formatted, again)
+		}
+
+		// formatting must not change what the program means
+		if parse(t, tt.input) != parse(t, string(formatted)) {
+			t.Errorf("formatting %q changed the program. got=%q", tt.input, formatted)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program.String()
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source([]byte("let = 5")); err == nil {
+		t.Errorf("expected an error for invalid source")
+	}
+}
+
+func TestNode(t *testing.T) {
+	p := parser.New(lexer.New("let f = fn(x) { if ((x > 1)) { x } else { -(x) } }"))
+	program := p.ParseProgram()
+	expected := "let f = fn(x) {\n\tif (x > 1) {\n\t\tx\n\t} else {\n\t\t-x\n\t}\n};"
+	if got := Node(program); got != expected {
+		t.Errorf("Node wrong.\nwant=%q\ngot= %q", expected, got)
+	}
+}
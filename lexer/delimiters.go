@@ -0,0 +1,50 @@
+package lexer
+
+import "github.com/josh-weston/go_interpreter/token"
+
+// MatchDelimiters maps the offset of every '(', '[' or '{' in src to the position of its
+// closer. The AST does not keep closing delimiters, so tools that lay out or scope code
+// by them look them up here.
+func MatchDelimiters(src string) map[int]token.Position {
+	closers := make(map[int]token.Position)
+	stack := []int{}
+	l := New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			stack = append(stack, tok.Pos.Offset)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(stack) > 0 {
+				closers[stack[len(stack)-1]] = tok.Pos
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return closers
+}
+
+// NextOpener returns the offset of the first opening delimiter after offset
+func NextOpener(closers map[int]token.Position, offset int) (int, bool) {
+	next, found := 0, false
+	for open := range closers {
+		if open > offset && (!found || open < next) {
+			next, found = open, true
+		}
+	}
+	return next, found
+}
+
+// BlockCloser returns the position of the brace closing the block of a construct such as
+// match (x) { ... }, whose keyword is at offset: the closer of the delimiter following
+// the parenthesised part
+func BlockCloser(closers map[int]token.Position, offset int) (token.Position, bool) {
+	paren, ok := NextOpener(closers, offset)
+	if !ok {
+		return token.Position{}, false
+	}
+	brace, ok := NextOpener(closers, closers[paren].Offset)
+	if !ok {
+		return token.Position{}, false
+	}
+	return closers[brace], true
+}
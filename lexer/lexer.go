@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"unicode"
 
 	"github.com/josh-weston/go_interpreter/token"
@@ -11,6 +12,11 @@ type Lexer struct {
 	position     int  // current positin in input (points to current char)
 	readPosition int  // current reading position in input (after current char). Peeks ahead a single char.
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+	offset       int  // the offset of input in the source, when lexing part of it
+
+	comments []token.Comment // comments skipped so far, in source order
 }
 
 /*
@@ -22,6 +28,10 @@ let my_variable_name
 */
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // ASCII code for NUL
 	} else {
@@ -29,6 +39,16 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition += 1 // only ever move one character at a time
+	l.column++
+}
+
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.offset + l.position, Line: l.line, Column: l.column}
+}
+
+// Comments returns the comments the lexer has skipped over so far
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
 }
 
 // reads identifier and advances our lexer's position until it encounters a non-letter-character
@@ -41,29 +61,92 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an INT, or a FLOAT if the digits are followed by a fraction: 1.5
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
 	// find the position it is no longer a digit
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return l.input[position:l.position], token.INT
+	}
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+	return l.input[position:l.position], token.FLOAT
 }
 
-func (l *Lexer) readString() string {
+// readString reads up to the closing quote. The text and the ${...} interpolations of a
+// template string are returned as parts, which are nil if there are no interpolations.
+func (l *Lexer) readString() (string, []token.Token) {
 	position := l.position + 1
+	var parts []token.Token
+	interpolated := false
+	l.readChar()
 	for {
-		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		text := token.Token{Type: token.STRING, Pos: l.pos()}
+		start := l.position
+		for l.ch != '"' && l.ch != 0 && (l.ch != '$' || l.peekChar() != '{') {
+			l.readChar()
+		}
+		if text.Literal = l.input[start:l.position]; text.Literal != "" {
+			parts = append(parts, text)
+		}
+		if l.ch != '$' {
 			break
 		}
+		l.readChar()
+		l.readChar()
+		parts = append(parts, l.readInterpolation())
+		interpolated = true
+		if l.ch == '}' {
+			l.readChar()
+		}
 	}
-	return l.input[position:l.position]
+	if !interpolated {
+		parts = nil
+	}
+	return l.input[position:l.position], parts
 }
 
+// readInterpolation reads the expression of a ${...}, starting at its first character and
+// stopping at the closing brace. Braces and strings inside the expression are skipped.
+func (l *Lexer) readInterpolation() token.Token {
+	tok := token.Token{Type: token.INTERPOLATION, Pos: l.pos()}
+	position := l.position
+	for depth := 0; l.ch != 0; l.readChar() {
+		if l.ch == '"' {
+			l.readString()
+		} else if l.ch == '{' {
+			depth++
+		} else if l.ch == '}' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+	tok.Literal = l.input[position:l.position]
+	return tok
+}
+
+// skipWhitespace skips whitespace and comments, recording the comments as it goes
 func (l *Lexer) skipWhitespace() {
-	for unicode.IsSpace(rune(l.ch)) {
-		l.readChar()
+	for {
+		for unicode.IsSpace(rune(l.ch)) {
+			l.readChar()
+		}
+		if l.ch != '/' || l.peekChar() != '/' {
+			return
+		}
+		pos := l.pos()
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		text := strings.TrimRight(l.input[pos.Offset-l.offset:l.position], " \t\r")
+		l.comments = append(l.comments, token.Comment{Pos: pos, Text: text})
 	}
 }
 
@@ -86,6 +169,7 @@ func isDigit(ch byte) bool {
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespace()
+	pos := l.pos()
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -93,6 +177,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -135,9 +222,30 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
+	case '?':
+		if l.peekChar() == '?' {
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		} else if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL, Literal: "?."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal, tok.Parts = l.readString()
+		if tok.Parts != nil {
+			tok.Type = token.TEMPLATE
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -145,16 +253,18 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal) // fn | let | true | false | if | else | return
-			return tok                                // early exit because readChar() is called by readIdentifier()
+			tok.Pos = pos
+			return tok // early exit because readChar() is called by readIdentifier()
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
@@ -165,7 +275,15 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 // New receives an input string (source code), and returns a Lexer
 // for creating tokens from the source code
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar() // initialize to the first character
 	return l
 }
+
+// NewAt returns a Lexer for input, a part of some source that starts at pos, so the
+// positions of its tokens are those in the whole source
+func NewAt(input string, pos token.Position) *Lexer {
+	l := &Lexer{input: input, line: pos.Line, column: pos.Column - 1, offset: pos.Offset}
+	l.readChar()
+	return l
+}
@@ -30,6 +30,11 @@ if (5 < 10) {
 [1, 2];
 {"foo": "bar"}
 macro(x, y) { x + y; };
+export let m = import "math";
+macro(...rest) ..
+match (x) { _ => 1 }
+a ?? null; a?.[0]?.(1) ?
+2.5 3.x
 `
 	tests := []struct {
 		expectedType    token.TokenType
@@ -134,6 +139,47 @@ macro(x, y) { x + y; };
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
 		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.IDENT, "m"},
+		{token.ASSIGN, "="},
+		{token.IMPORT, "import"},
+		{token.STRING, "math"},
+		{token.SEMICOLON, ";"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.NULLISH, "??"},
+		{token.NULL, "null"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.OPTIONAL, "?."},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.OPTIONAL, "?."},
+		{token.LPAREN, "("},
+		{token.INT, "1"},
+		{token.RPAREN, ")"},
+		{token.ILLEGAL, "?"},
+		{token.FLOAT, "2.5"},
+		{token.INT, "3"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
 		{token.EOF, ""},
 	}
 
@@ -152,3 +198,117 @@ macro(x, y) { x + y; };
 	}
 
 }
+
+func TestTemplateString(t *testing.T) {
+	input := `"Hi ${user.name}, ${len(items)} ${f("}")}!" "$5 {x}"`
+
+	l := New(input)
+	tok := l.NextToken()
+	if tok.Type != token.TEMPLATE || tok.Literal != `Hi ${user.name}, ${len(items)} ${f("}")}!` {
+		t.Fatalf("wrong token. got=%q %q", tok.Type, tok.Literal)
+	}
+	expected := []token.Token{
+		{Type: token.STRING, Literal: "Hi ", Pos: token.Position{Offset: 1, Line: 1, Column: 2}},
+		{Type: token.INTERPOLATION, Literal: "user.name", Pos: token.Position{Offset: 6, Line: 1, Column: 7}},
+		{Type: token.STRING, Literal: ", ", Pos: token.Position{Offset: 16, Line: 1, Column: 17}},
+		{Type: token.INTERPOLATION, Literal: "len(items)", Pos: token.Position{Offset: 20, Line: 1, Column: 21}},
+		{Type: token.STRING, Literal: " ", Pos: token.Position{Offset: 31, Line: 1, Column: 32}},
+		{Type: token.INTERPOLATION, Literal: `f("}")`, Pos: token.Position{Offset: 34, Line: 1, Column: 35}},
+		{Type: token.STRING, Literal: "!", Pos: token.Position{Offset: 41, Line: 1, Column: 42}},
+	}
+	if len(tok.Parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d (%+v)", len(expected), len(tok.Parts), tok.Parts)
+	}
+	for i, want := range expected {
+		if got := tok.Parts[i]; got.Type != want.Type || got.Literal != want.Literal || got.Pos != want.Pos {
+			t.Errorf("parts[%d] wrong. expected=%+v, got=%+v", i, want, tok.Parts[i])
+		}
+	}
+
+	// a $ or { on its own is plain text
+	tok = l.NextToken()
+	if tok.Type != token.STRING || tok.Literal != "$5 {x}" || tok.Parts != nil {
+		t.Errorf("wrong token. got=%+v", tok)
+	}
+
+	// the source of an interpolation is lexed where it stands in the whole source
+	sub := NewAt("a +\n b", token.Position{Offset: 10, Line: 3, Column: 5})
+	for _, want := range []token.Position{{Offset: 10, Line: 3, Column: 5}, {Offset: 12, Line: 3, Column: 7}, {Offset: 15, Line: 4, Column: 2}} {
+		if tok := sub.NextToken(); tok.Pos != want {
+			t.Errorf("%q: wrong position. expected=%+v, got=%+v", tok.Literal, want, tok.Pos)
+		}
+	}
+}
+
+func TestPositionsAndComments(t *testing.T) {
+	input := `let x = 5; // five
+// on its own line
+  x + "a b";`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{token.IDENT, token.Position{Offset: 40, Line: 3, Column: 3}},
+		{token.PLUS, token.Position{Offset: 42, Line: 3, Column: 5}},
+		{token.STRING, token.Position{Offset: 44, Line: 3, Column: 7}},
+		{token.SEMICOLON, token.Position{Offset: 49, Line: 3, Column: 12}},
+		{token.EOF, token.Position{Offset: 50, Line: 3, Column: 13}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%#v, got=%#v", i, tt.expectedPos, tok.Pos)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != 2 {
+		t.Fatalf("wrong number of comments. got=%d", len(comments))
+	}
+	if comments[0].Text != "// five" || comments[0].Pos.Line != 1 || comments[0].Pos.Column != 12 {
+		t.Errorf("wrong first comment. got=%+v", comments[0])
+	}
+	if comments[1].Text != "// on its own line" || comments[1].Pos.Line != 2 {
+		t.Errorf("wrong second comment. got=%+v", comments[1])
+	}
+}
+
+func TestMatchDelimiters(t *testing.T) {
+	closers := MatchDelimiters("f(a[0], {})\n)")
+	expected := map[int]token.Position{
+		1: {Offset: 10, Line: 1, Column: 11},
+		3: {Offset: 5, Line: 1, Column: 6},
+		8: {Offset: 9, Line: 1, Column: 10},
+	}
+	if len(closers) != len(expected) {
+		t.Fatalf("wrong number of pairs. expected=%d, got=%d (%v)", len(expected), len(closers), closers)
+	}
+	for open, want := range expected {
+		if closers[open] != want {
+			t.Errorf("closer of %d wrong. expected=%+v, got=%+v", open, want, closers[open])
+		}
+	}
+}
+
+func TestBlockCloser(t *testing.T) {
+	src := "match (f(x)) { [a] => { a } }"
+	closers := MatchDelimiters(src)
+	closer, ok := BlockCloser(closers, 0)
+	if !ok || closer.Offset != len(src)-1 {
+		t.Errorf("wrong closer. expected offset %d, got %+v (%t)", len(src)-1, closer, ok)
+	}
+	if _, ok := BlockCloser(MatchDelimiters("match (x)"), 0); ok {
+		t.Errorf("expected no closer without a block")
+	}
+}
@@ -0,0 +1,267 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/josh-weston/go_interpreter/analysis"
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/format"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+// document is an open file together with everything derived from its text
+type document struct {
+	uri        string
+	text       string
+	lineStarts []int // byte offset at which each line begins
+	program    *ast.Program
+	errors     []*parser.Error
+	info       *analysis.Info
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lineStarts = append(doc.lineStarts, i+1)
+		}
+	}
+	p := parser.New(lexer.New(text))
+	doc.program = p.ParseProgram()
+	doc.errors = p.ErrorList()
+	doc.info = analysis.Analyze(doc.program, text)
+	return doc
+}
+
+// offset converts an LSP position into a byte offset, clamped to the document
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+	offset := d.lineStarts[pos.Line]
+	for units := 0; units < pos.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// position converts a byte offset into an LSP position
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16.RuneLen(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+func (d *document) span(offset, length int) Range {
+	return Range{Start: d.position(offset), End: d.position(offset + length)}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return d.span(ident.Token.Pos.Offset, len(ident.Value))
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diags := []Diagnostic{}
+	for _, err := range d.errors {
+		offset := len(d.text)
+		if err.Pos.IsValid() {
+			offset = err.Pos.Offset
+		}
+		diags = append(diags, Diagnostic{
+			Range:    d.span(offset, d.tokenLength(offset)),
+			Severity: SeverityError,
+			Source:   "monkey",
+			Message:  err.Msg,
+		})
+	}
+	for _, diag := range d.info.Diagnostics {
+		severity := SeverityError
+		if diag.Severity == analysis.Warning {
+			severity = SeverityWarning
+		}
+		diags = append(diags, Diagnostic{
+			Range:    d.span(diag.Pos.Offset, d.tokenLength(diag.Pos.Offset)),
+			Severity: severity,
+			Source:   "monkey",
+			Message:  diag.Msg,
+		})
+	}
+	return diags
+}
+
+// tokenLength returns the length of the token starting at offset
+func (d *document) tokenLength(offset int) int {
+	if offset >= len(d.text) {
+		return 0
+	}
+	tok := lexer.New(d.text[offset:]).NextToken()
+	if tok.Type == token.EOF || tok.Type == token.ILLEGAL {
+		return 1
+	}
+	if tok.Type == token.STRING || tok.Type == token.TEMPLATE {
+		return len(tok.Literal) + 2
+	}
+	return len(tok.Literal)
+}
+
+func (d *document) definition(offset int) *Location {
+	ident := d.info.IdentAt(offset)
+	if ident == nil {
+		return nil
+	}
+	b, ok := d.info.Refs[ident]
+	if !ok || b.Kind == analysis.Builtin {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.identRange(b.Ident)}
+}
+
+func (d *document) hover(offset int) *Hover {
+	ident := d.info.IdentAt(offset)
+	if ident == nil {
+		return nil
+	}
+	b, ok := d.info.Refs[ident]
+	if !ok {
+		return nil
+	}
+	signature, doc := describe(b), ""
+	if builtin, ok := evaluator.LookupBuiltin(ident.Value); ok && b.Kind == analysis.Builtin {
+		signature, doc = builtin.Signature, builtin.Doc
+	}
+	value := "```monkey\n" + signature + "\n```"
+	if doc != "" {
+		value += "\n" + doc
+	}
+	r := d.identRange(ident)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &r}
+}
+
+// describe renders a binding the way it was declared, e.g. "let add = fn(x, y)"
+func describe(b *analysis.Binding) string {
+	switch b.Kind {
+	case analysis.Builtin:
+		return b.Name + " (builtin)"
+	case analysis.Param:
+		if b.Scope.Owner != "" {
+			return fmt.Sprintf("%s (parameter of %s)", b.Name, b.Scope.Owner)
+		}
+		return b.Name + " (parameter)"
+	case analysis.Match:
+		return b.Name + " (bound by match)"
+	}
+	switch value := b.Value.(type) {
+	case *ast.FunctionLiteral:
+		return fmt.Sprintf("let %s = fn(%s)", b.Name, parameterList(value.Parameters, value.Patterns, value.Defaults, value.Rest))
+	case *ast.MacroLiteral:
+		return fmt.Sprintf("let %s = macro(%s)", b.Name, parameterList(value.Parameters, nil, nil, value.Rest))
+	}
+	return "let " + b.Name
+}
+
+// parameterList returns the parameters as they are written in source
+func parameterList(params []*ast.Identifier, patterns, defaults []ast.Expression, rest *ast.Identifier) string {
+	names := make([]string, len(params))
+	for i, ident := range params {
+		if i < len(patterns) && patterns[i] != nil {
+			names[i] = patterns[i].String()
+		} else {
+			names[i] = ident.Value
+		}
+		if i < len(defaults) && defaults[i] != nil {
+			names[i] += " = " + defaults[i].String()
+		}
+	}
+	if rest != nil {
+		names = append(names, "..."+rest.Value)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (d *document) symbols() []SymbolInformation {
+	symbols := []SymbolInformation{}
+	for _, b := range d.info.Bindings {
+		if b.Kind != analysis.Let {
+			continue
+		}
+		kind := SymbolVariable
+		switch b.Value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			kind = SymbolFunction
+		}
+		symbols = append(symbols, SymbolInformation{
+			Name:          b.Name,
+			Kind:          kind,
+			Location:      Location{URI: d.uri, Range: d.identRange(b.Ident)},
+			ContainerName: b.Scope.Owner,
+		})
+	}
+	return symbols
+}
+
+// completion offers the names in scope at offset followed by the builtins and keywords
+func (d *document) completion(offset int) []CompletionItem {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	inner := d.info.ScopeAt(offset)
+	for s := inner; s != nil; s = s.Parent {
+		for i := len(s.Bindings) - 1; i >= 0; i-- {
+			b := s.Bindings[i]
+			// later bindings of the current scope, and the name being typed, are not defined yet
+			if s == inner && b.Ident.Token.Pos.Offset+len(b.Name) >= offset {
+				continue
+			}
+			kind, detail := CompletionVariable, describe(b)
+			switch b.Value.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				kind = CompletionFunction
+			}
+			if b.Kind == analysis.Builtin {
+				kind = CompletionFunction
+				if builtin, ok := evaluator.LookupBuiltin(b.Name); ok {
+					detail = builtin.Signature
+				}
+			}
+			add(CompletionItem{Label: b.Name, Kind: kind, Detail: detail})
+		}
+	}
+	for _, kw := range token.Keywords() {
+		add(CompletionItem{Label: kw, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// format returns an edit replacing the whole document with its formatted text, or no edits
+// if the document does not parse or is already formatted
+func (d *document) format() []TextEdit {
+	formatted, err := format.Source([]byte(d.text))
+	if err != nil || string(formatted) == d.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: d.span(0, len(d.text)), NewText: string(formatted)}}
+}
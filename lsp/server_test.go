@@ -0,0 +1,374 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.monkey"
+
+const testSource = `let add = fn(x, y) {
+  let sum = x + y;
+  sum
+};
+let total = add(1, 2);
+puts(total);
+`
+
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// session runs the server over the given messages and returns what it wrote back
+func session(t *testing.T, msgs ...interface{}) []message {
+	t.Helper()
+	var in bytes.Buffer
+	for _, msg := range msgs {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var out bytes.Buffer
+	if err := Serve(&in, &out); err != nil {
+		t.Fatalf("Serve returned %v", err)
+	}
+
+	var replies []message
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			t.Fatal(err)
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, msg)
+	}
+}
+
+func call(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func open(text string) map[string]interface{} {
+	return notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "monkey", Version: 1, Text: text},
+	})
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+// result finds the reply to request id and decodes its result into v
+func result(t *testing.T, replies []message, id int, v interface{}) {
+	t.Helper()
+	for _, msg := range replies {
+		if msg.ID != nil && *msg.ID == id {
+			if msg.Error != nil {
+				t.Fatalf("request %d failed: %s", id, msg.Error.Message)
+			}
+			if err := json.Unmarshal(msg.Result, v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no reply to request %d", id)
+}
+
+func TestInitializeAndShutdown(t *testing.T) {
+	replies := session(t,
+		call(1, "initialize", map[string]interface{}{}),
+		notify("initialized", map[string]interface{}{}),
+		call(2, "shutdown", nil),
+		notify("exit", nil),
+	)
+	var init InitializeResult
+	result(t, replies, 1, &init)
+	caps := init.Capabilities
+	if caps.TextDocumentSync != textDocumentSyncFull || !caps.HoverProvider || !caps.DefinitionProvider ||
+		!caps.DocumentSymbolProvider || caps.CompletionProvider == nil || !caps.DocumentFormattingProvider {
+		t.Errorf("unexpected capabilities %+v", caps)
+	}
+	if len(replies) != 2 {
+		t.Errorf("expected 2 replies, got %d", len(replies))
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	var in, out bytes.Buffer
+	body := `{"jsonrpc":"2.0","method":"exit"}`
+	fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	if err := Serve(&in, &out); err != ErrNoShutdown {
+		t.Errorf("expected ErrNoShutdown, got %v", err)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	replies := session(t, call(1, "textDocument/rename", nil), notify("$/cancelRequest", nil))
+	if len(replies) != 1 || replies[0].Error == nil || replies[0].Error.Code != codeMethodNotFound {
+		t.Fatalf("expected a single method not found error, got %+v", replies)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	replies := session(t,
+		open("let x = 5;\nlet = 10;\n"),
+		notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   TextDocumentIdentifier{URI: testURI},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 5;\n"}},
+		}),
+	)
+	if len(replies) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(replies))
+	}
+
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(replies[0].Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if replies[0].Method != "textDocument/publishDiagnostics" || params.URI != testURI {
+		t.Fatalf("unexpected notification %+v", replies[0])
+	}
+	if len(params.Diagnostics) == 0 {
+		t.Fatal("expected diagnostics for the invalid let")
+	}
+	diag := params.Diagnostics[0]
+	want := Range{Start: Position{1, 4}, End: Position{1, 5}}
+	if diag.Range != want || diag.Severity != SeverityError {
+		t.Errorf("wrong diagnostic %+v, want range %+v", diag, want)
+	}
+	if !strings.Contains(diag.Message, "expected next token to be 'IDENT'") {
+		t.Errorf("wrong message %q", diag.Message)
+	}
+
+	// fixing the document clears the diagnostics
+	if err := json.Unmarshal(replies[1].Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if len(params.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %+v", params.Diagnostics)
+	}
+}
+
+func TestAnalysisDiagnostics(t *testing.T) {
+	replies := session(t, open("let f = fn() {\n  let y = 1;\n  z\n};\n"))
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(replies[0].Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Diagnostic{
+		{Range{Position{1, 6}, Position{1, 7}}, SeverityWarning, "monkey", "y declared but not used"},
+		{Range{Position{2, 2}, Position{2, 3}}, SeverityError, "monkey", "undefined: z"},
+	}
+	if len(params.Diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(expected), params.Diagnostics)
+	}
+	for i, want := range expected {
+		if params.Diagnostics[i] != want {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, want, params.Diagnostics[i])
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        *Range
+	}{
+		{1, 12, &Range{Position{0, 13}, Position{0, 14}}}, // x, a parameter
+		{2, 2, &Range{Position{1, 6}, Position{1, 9}}},    // sum, a local let
+		{4, 12, &Range{Position{0, 4}, Position{0, 7}}},   // add, a global function
+		{5, 7, &Range{Position{4, 4}, Position{4, 9}}},    // total, from inside puts(...)
+		{5, 1, nil}, // puts is a builtin
+		{3, 0, nil}, // not an identifier
+	}
+
+	msgs := []interface{}{open(testSource)}
+	for i, tt := range tests {
+		msgs = append(msgs, call(i+1, "textDocument/definition", at(tt.line, tt.character)))
+	}
+	replies := session(t, msgs...)
+
+	for i, tt := range tests {
+		var loc *Location
+		result(t, replies, i+1, &loc)
+		if tt.expected == nil {
+			if loc != nil {
+				t.Errorf("test %d: expected no definition, got %+v", i, loc)
+			}
+			continue
+		}
+		if loc == nil || loc.URI != testURI || loc.Range != *tt.expected {
+			t.Errorf("test %d: expected %+v, got %+v", i, tt.expected, loc)
+		}
+	}
+}
+
+func TestDefinitionOfLaterGlobal(t *testing.T) {
+	src := "let f = fn() { g() };\nlet g = fn() { 1 };\n"
+	replies := session(t, open(src), call(1, "textDocument/definition", at(0, 15)))
+	var loc *Location
+	result(t, replies, 1, &loc)
+	if loc == nil || loc.Range.Start != (Position{1, 4}) {
+		t.Errorf("expected g on line 2, got %+v", loc)
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{5, 2, "```monkey\nputs(values...)\n```\n"},
+		{4, 13, "```monkey\nlet add = fn(x, y)\n```"},
+		{1, 16, "```monkey\ny (parameter of add)\n```"},
+	}
+
+	msgs := []interface{}{open(testSource)}
+	for i, tt := range tests {
+		msgs = append(msgs, call(i+1, "textDocument/hover", at(tt.line, tt.character)))
+	}
+	replies := session(t, msgs...)
+
+	for i, tt := range tests {
+		var hover Hover
+		result(t, replies, i+1, &hover)
+		if hover.Contents.Kind != "markdown" || !strings.HasPrefix(hover.Contents.Value, tt.expected) {
+			t.Errorf("test %d: expected %q, got %q", i, tt.expected, hover.Contents.Value)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	replies := session(t, open(testSource), call(1, "textDocument/documentSymbol", DocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+	}))
+	var symbols []SymbolInformation
+	result(t, replies, 1, &symbols)
+
+	expected := []struct {
+		name      string
+		kind      SymbolKind
+		container string
+	}{
+		{"add", SymbolFunction, ""},
+		{"sum", SymbolVariable, "add"},
+		{"total", SymbolVariable, ""},
+	}
+	if len(symbols) != len(expected) {
+		t.Fatalf("expected %d symbols, got %+v", len(expected), symbols)
+	}
+	for i, want := range expected {
+		got := symbols[i]
+		if got.Name != want.name || got.Kind != want.kind || got.ContainerName != want.container {
+			t.Errorf("symbol %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	labels := func(line, character int) map[string]CompletionItemKind {
+		replies := session(t, open(testSource), call(1, "textDocument/completion", at(line, character)))
+		var items []CompletionItem
+		result(t, replies, 1, &items)
+		kinds := make(map[string]CompletionItemKind)
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+		}
+		return kinds
+	}
+
+	// inside add after sum has been declared
+	inside := labels(2, 2)
+	for _, name := range []string{"x", "y", "sum", "add", "len", "puts"} {
+		if _, ok := inside[name]; !ok {
+			t.Errorf("expected %s to be offered inside add", name)
+		}
+	}
+	if inside["add"] != CompletionFunction || inside["fn"] != CompletionKeyword {
+		t.Errorf("wrong kinds %v", inside)
+	}
+
+	// at the top level parameters and locals are out of scope
+	outside := labels(5, 0)
+	for _, name := range []string{"x", "sum"} {
+		if _, ok := outside[name]; ok {
+			t.Errorf("did not expect %s at the top level", name)
+		}
+	}
+	if _, ok := outside["total"]; !ok {
+		t.Error("expected total at the top level")
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	replies := session(t,
+		open("let x=1;x+2"),
+		call(1, "textDocument/formatting", DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}),
+	)
+	var edits []TextEdit
+	result(t, replies, 1, &edits)
+	if len(edits) != 1 {
+		t.Fatalf("expected a single edit, got %+v", edits)
+	}
+	if edits[0].NewText != "let x = 1;\nx + 2;\n" {
+		t.Errorf("wrong formatted text %q", edits[0].NewText)
+	}
+	if edits[0].Range != (Range{Position{0, 0}, Position{0, 11}}) {
+		t.Errorf("edit should cover the whole document, got %+v", edits[0].Range)
+	}
+}
+
+func TestPositionConversion(t *testing.T) {
+	doc := newDocument(testURI, "let s = \"héllo😀\";\nx")
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{0, 0}},
+		{12, Position{0, 11}}, // after the two byte é
+		{19, Position{0, 16}}, // the emoji takes two UTF-16 units
+		{22, Position{1, 0}},
+	}
+	for _, tt := range tests {
+		pos := doc.position(tt.offset)
+		if pos != tt.expected {
+			t.Errorf("position(%d) = %+v, want %+v", tt.offset, pos, tt.expected)
+		}
+		if back := doc.offset(pos); back != tt.offset {
+			t.Errorf("offset(%+v) = %d, want %d", pos, back, tt.offset)
+		}
+	}
+}
@@ -4,11 +4,46 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 
+	"github.com/josh-weston/go_interpreter/dap"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lsp"
+	"github.com/josh-weston/go_interpreter/object"
 	"github.com/josh-weston/go_interpreter/repl"
 )
 
 func main() {
+	// MONKEYPATH extends the directories searched by import, like PATH
+	if path := os.Getenv("MONKEYPATH"); path != "" {
+		evaluator.DefaultImporter.SearchPath = append(evaluator.DefaultImporter.SearchPath, filepath.SplitList(path)...)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(fmtCommand(os.Args[2:]))
+		case "ast":
+			os.Exit(astCommand(os.Args[2:]))
+		case "vet":
+			os.Exit(vetCommand(os.Args[2:]))
+		case "dap":
+			if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "lsp":
+			if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		default:
+			os.Exit(runFile(os.Args[1]))
+		}
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -18,3 +53,17 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// runFile evaluates a script as the main module and returns the process exit code
+func runFile(path string) int {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if result, ok := evaluator.DefaultImporter.Import(abs).(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, result.Inspect())
+		return 1
+	}
+	return 0
+}
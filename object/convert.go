@@ -0,0 +1,95 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The conversion protocol behind str(), int() and float(). Every value converts to a
+// STRING; a type converts to a number by implementing IntConverter or FloatConverter,
+// whose methods return an error saying why a particular value does not convert.
+
+// IntConverter is implemented by the types that int() converts
+type IntConverter interface {
+	ToInt() (int64, error)
+}
+
+// FloatConverter is implemented by the types that float() converts
+type FloatConverter interface {
+	ToFloat() (float64, error)
+}
+
+// Str returns obj converted to a string: a STRING is its own text, NULL is written as
+// the literal null, and any other value reads as it prints
+func Str(obj Object) string {
+	switch obj := obj.(type) {
+	case *String:
+		return obj.Value
+	case *Null:
+		return "null"
+	default:
+		return obj.Inspect()
+	}
+}
+
+// ToInt converts obj to an INTEGER, or returns why it cannot
+func ToInt(obj Object) (int64, error) {
+	if c, ok := obj.(IntConverter); ok {
+		return c.ToInt()
+	}
+	return 0, fmt.Errorf("cannot convert %s to %s", obj.Type(), INTEGER_OBJ)
+}
+
+// ToFloat converts obj to a FLOAT, or returns why it cannot
+func ToFloat(obj Object) (float64, error) {
+	if c, ok := obj.(FloatConverter); ok {
+		return c.ToFloat()
+	}
+	return 0, fmt.Errorf("cannot convert %s to %s", obj.Type(), FLOAT_OBJ)
+}
+
+func (i *Integer) ToInt() (int64, error)     { return i.Value, nil }
+func (i *Integer) ToFloat() (float64, error) { return float64(i.Value), nil }
+
+// ToInt drops the fraction, rounding toward zero
+func (f *Float) ToInt() (int64, error) {
+	if math.IsNaN(f.Value) || f.Value >= math.MaxInt64 || f.Value < math.MinInt64 {
+		return 0, fmt.Errorf("cannot convert %s to %s, it is out of range", f.Inspect(), INTEGER_OBJ)
+	}
+	return int64(f.Value), nil
+}
+func (f *Float) ToFloat() (float64, error) { return f.Value, nil }
+
+func (b *Boolean) ToInt() (int64, error) {
+	if b.Value {
+		return 1, nil
+	}
+	return 0, nil
+}
+func (b *Boolean) ToFloat() (float64, error) {
+	i, _ := b.ToInt()
+	return float64(i), nil
+}
+
+// ToInt parses the decimal integer the string holds, ignoring white space around it
+func (s *String) ToInt() (int64, error) {
+	value, err := strconv.ParseInt(strings.TrimSpace(s.Value), 10, 64)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			return 0, fmt.Errorf("cannot convert %q to %s, it is out of range", s.Value, INTEGER_OBJ)
+		}
+		return 0, fmt.Errorf("cannot convert %q to %s", s.Value, INTEGER_OBJ)
+	}
+	return value, nil
+}
+
+// ToFloat parses the number the string holds, ignoring white space around it
+func (s *String) ToFloat() (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(s.Value), 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("cannot convert %q to %s", s.Value, FLOAT_OBJ)
+	}
+	return value, nil
+}
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
@@ -18,6 +20,21 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Names returns the sorted names bound directly in this environment, not including outer scopes
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer returns the enclosing environment, or nil for the global environment
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s}
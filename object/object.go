@@ -3,6 +3,8 @@ package object
 import (
 	"fmt"
 	"hash/fnv"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/josh-weston/go_interpreter/ast"
@@ -16,6 +18,7 @@ type BuiltInFunction func(args ...Object) Object
 // will be an implicity conversion when assigned
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -27,6 +30,7 @@ const (
 	HASH_OBJ         = "HASH"
 	QUOTE_OBJ        = "QUOTE"
 	MACRO_OBJ        = "MACRO"
+	MODULE_OBJ       = "MODULE"
 )
 
 type Object interface {
@@ -41,6 +45,13 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Inspect() string  { return ast.FormatFloat(f.Value) }
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 type Boolean struct {
 	Value bool
 }
@@ -51,7 +62,7 @@ func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 type Null struct{} // represents the absence of a value
 
 func (n *Null) Inspect() string  { return NULL_OBJ }
-func (n *Null) Type() ObjectType { return "null" }
+func (n *Null) Type() ObjectType { return NULL_OBJ }
 
 type ReturnValue struct {
 	Value Object
@@ -65,10 +76,13 @@ type Error struct {
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERRORL: " + e.Message }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Parameters []*ast.Identifier // nil where the parameter is a pattern
+	Patterns   []ast.Expression  // the pattern taking each argument apart, nil where there is none
+	Defaults   []ast.Expression  // the default value of each parameter, nil where there is none
+	Rest       *ast.Identifier   // collects the remaining arguments, nil if there is none
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -77,14 +91,26 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var sb strings.Builder
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		param := ""
+		if i < len(f.Patterns) && f.Patterns[i] != nil {
+			param = f.Patterns[i].String()
+		} else {
+			param = p.String()
+		}
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+		params = append(params, param)
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 	sb.WriteString("fn(")
 	sb.WriteString(strings.Join(params, ","))
 	sb.WriteString(") {\n")
 	sb.WriteString(f.Body.String())
-	sb.WriteString("\n")
+	sb.WriteString("\n}")
 	return sb.String()
 }
 
@@ -96,7 +122,41 @@ func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
 type Builtin struct {
-	Fn BuiltInFunction
+	Fn        BuiltInFunction
+	Signature string // how the builtin is called, e.g. "len(value)"
+	Doc       string // one line description shown by editor tooling
+	Params    []BuiltinParam
+	Variadic  bool // the last parameter takes any number of arguments, including none
+}
+
+// BuiltinParam declares a parameter of a builtin so that calls can be checked statically
+type BuiltinParam struct {
+	Name  string
+	Types []ObjectType // the accepted types, empty if any type is
+}
+
+// CheckArity returns a description of what is wrong with calling b with n arguments, or
+// an empty string if n is acceptable
+func (b *Builtin) CheckArity(n int) string {
+	want := len(b.Params)
+	switch {
+	case b.Variadic && n < want-1:
+		return fmt.Sprintf("got=%d, want at least %d", n, want-1)
+	case !b.Variadic && n != want:
+		return fmt.Sprintf("got=%d, want=%d", n, want)
+	}
+	return ""
+}
+
+// Param returns the declaration of the i-th argument of a call
+func (b *Builtin) Param(i int) (BuiltinParam, bool) {
+	if i < len(b.Params) {
+		return b.Params[i], true
+	}
+	if b.Variadic && len(b.Params) > 0 {
+		return b.Params[len(b.Params)-1], true
+	}
+	return BuiltinParam{}, false
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -140,6 +200,10 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 // OPTIMIZATION: cache the result of HashKey() so it isn't computed each time it is called
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
@@ -186,6 +250,7 @@ func (q *Quote) Inspect() string {
 
 type Macro struct {
 	Parameters []*ast.Identifier
+	Rest       *ast.Identifier // collects the arguments after Parameters, nil if there is none
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -197,10 +262,32 @@ func (f *Macro) Inspect() string {
 	for _, p := range f.Parameters {
 		params = append(params, p.String())
 	}
-	sb.WriteString("fn(")
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+	sb.WriteString("macro(")
 	sb.WriteString(strings.Join(params, ","))
 	sb.WriteString(") {\n")
 	sb.WriteString(f.Body.String())
-	sb.WriteString("\n")
+	sb.WriteString("\n}")
 	return sb.String()
 }
+
+// Module is the namespace produced by an import expression. Only bindings
+// declared with `export let` are visible through it.
+type Module struct {
+	Name    string
+	Path    string // absolute path of the source file
+	Exports map[string]Object
+	Macros  map[string]*Macro // the exported macros, used while expanding the importing code
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+	names := make([]string, 0, len(m.Exports))
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("module %s {%s}", m.Name, strings.Join(names, ", "))
+}
@@ -58,3 +58,23 @@ func TestInterHashKey(t *testing.T) {
 		t.Errorf("integers with different content have same hash keys")
 	}
 }
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", &Integer{Value: 3})
+
+	names := outer.Names()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("outer.Names() wrong. got=%v", names)
+	}
+	names = inner.Names()
+	if len(names) != 1 || names[0] != "c" {
+		t.Errorf("inner.Names() wrong. got=%v", names)
+	}
+	if inner.Outer() != outer {
+		t.Errorf("inner.Outer() is not the enclosing environment")
+	}
+}
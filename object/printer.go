@@ -0,0 +1,144 @@
+package object
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ANSI colour codes used when Printer.Color is set
+var typeColors = map[ObjectType]string{
+	INTEGER_OBJ:  "\x1b[33m", // yellow
+	FLOAT_OBJ:    "\x1b[33m",
+	STRING_OBJ:   "\x1b[32m", // green
+	BOOLEAN_OBJ:  "\x1b[35m", // magenta
+	NULL_OBJ:     "\x1b[90m", // grey
+	ERROR_OBJ:    "\x1b[31m", // red
+	FUNCTION_OBJ: "\x1b[34m", // blue
+	BUILTIN_OBJ:  "\x1b[34m",
+	MACRO_OBJ:    "\x1b[34m",
+	QUOTE_OBJ:    "\x1b[36m", // cyan
+	MODULE_OBJ:   "\x1b[36m",
+}
+
+const colorReset = "\x1b[0m"
+
+// Printer renders objects for people rather than for debugging: strings are quoted,
+// nested collections are indented when they do not fit on one line and recursive
+// structures are cut short instead of looping forever.
+type Printer struct {
+	Width  int    // collections wider than this are broken over several lines
+	Indent string // added per nesting level when a collection is broken
+	Color  bool   // colour values by type with ANSI escape codes
+}
+
+func NewPrinter() *Printer {
+	return &Printer{Width: 80, Indent: "  "}
+}
+
+// Sprint returns the rendering of obj
+func (p *Printer) Sprint(obj Object) string {
+	var sb strings.Builder
+	p.write(&sb, obj, "", 0, map[Object]bool{})
+	return sb.String()
+}
+
+// write renders obj starting at the given column, breaking collections over several lines if needed
+func (p *Printer) write(sb *strings.Builder, obj Object, indent string, column int, visiting map[Object]bool) {
+	flat := p.flat(obj, false, visiting)
+	if column+len(flat) <= p.Width || visiting[obj] {
+		sb.WriteString(p.flat(obj, p.Color, visiting))
+		return
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		if len(obj.Elements) == 0 {
+			sb.WriteString("[]")
+			return
+		}
+		visiting[obj] = true
+		inner := indent + p.Indent
+		sb.WriteString("[\n")
+		for _, el := range obj.Elements {
+			sb.WriteString(inner)
+			p.write(sb, el, inner, len(inner), visiting)
+			sb.WriteString(",\n")
+		}
+		sb.WriteString(indent + "]")
+		delete(visiting, obj)
+	case *Hash:
+		if len(obj.Pairs) == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		visiting[obj] = true
+		inner := indent + p.Indent
+		sb.WriteString("{\n")
+		for _, pair := range p.sortedPairs(obj, visiting) {
+			key := p.flat(pair.Key, false, visiting)
+			sb.WriteString(inner + p.flat(pair.Key, p.Color, visiting) + ": ")
+			p.write(sb, pair.Value, inner, len(inner)+len(key)+2, visiting)
+			sb.WriteString(",\n")
+		}
+		sb.WriteString(indent + "}")
+		delete(visiting, obj)
+	default:
+		sb.WriteString(p.flat(obj, p.Color, visiting))
+	}
+}
+
+// flat renders obj on a single line
+func (p *Printer) flat(obj Object, color bool, visiting map[Object]bool) string {
+	var s string
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		elements := []string{}
+		for _, el := range obj.Elements {
+			elements = append(elements, p.flat(el, color, visiting))
+		}
+		delete(visiting, obj)
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		pairs := []string{}
+		for _, pair := range p.sortedPairs(obj, visiting) {
+			pairs = append(pairs, p.flat(pair.Key, color, visiting)+": "+p.flat(pair.Value, color, visiting))
+		}
+		delete(visiting, obj)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *String:
+		s = strconv.Quote(obj.Value)
+	case *Error:
+		s = "ERROR: " + obj.Message
+	case *ReturnValue:
+		return p.flat(obj.Value, color, visiting)
+	default:
+		s = strings.Join(strings.Fields(obj.Inspect()), " ")
+	}
+	if color {
+		if code, ok := typeColors[obj.Type()]; ok {
+			return code + s + colorReset
+		}
+	}
+	return s
+}
+
+// sortedPairs orders the pairs of a hash by their rendered key so output is stable
+func (p *Printer) sortedPairs(h *Hash, visiting map[Object]bool) []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return p.flat(pairs[i].Key, false, visiting) < p.flat(pairs[j].Key, false, visiting)
+	})
+	return pairs
+}
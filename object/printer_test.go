@@ -0,0 +1,73 @@
+package object
+
+import (
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Pairs[pairs[i].(Hashable).HashKey()] = HashPair{Key: pairs[i], Value: pairs[i+1]}
+		}
+		return h
+	}
+	str := func(s string) *String { return &String{Value: s} }
+	num := func(i int64) *Integer { return &Integer{Value: i} }
+
+	tests := []struct {
+		input    Object
+		width    int
+		expected string
+	}{
+		{num(1), 80, `1`},
+		{str("1"), 80, `"1"`},
+		{str("say \"hi\"\n"), 80, `"say \"hi\"\n"`},
+		{&Null{}, 80, `NULL`},
+		{&Error{Message: "boom"}, 80, `ERROR: boom`},
+		{&Array{Elements: []Object{num(1), str("a"), &Array{}}}, 80, `[1, "a", []]`},
+		{hash(str("b"), num(2), str("a"), num(1)), 80, `{"a": 1, "b": 2}`},
+		{
+			&Array{Elements: []Object{num(1), &Array{Elements: []Object{num(2), num(3)}}}},
+			10,
+			"[\n  1,\n  [2, 3],\n]",
+		},
+		{
+			hash(str("key"), &Array{Elements: []Object{str("long value"), str("another")}}),
+			20,
+			"{\n  \"key\": [\n    \"long value\",\n    \"another\",\n  ],\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		p := NewPrinter()
+		p.Width = tt.width
+		if got := p.Sprint(tt.input); got != tt.expected {
+			t.Errorf("wrong output. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestPrinterCycles(t *testing.T) {
+	arr := &Array{}
+	arr.Elements = []Object{&Integer{Value: 1}, arr}
+
+	p := NewPrinter()
+	if got := p.Sprint(arr); got != "[1, [...]]" {
+		t.Errorf("wrong output. got=%q", got)
+	}
+	p.Width = 4
+	if got := p.Sprint(arr); got != "[\n  1,\n  [...],\n]" {
+		t.Errorf("wrong output. got=%q", got)
+	}
+}
+
+func TestPrinterColor(t *testing.T) {
+	p := NewPrinter()
+	p.Color = true
+	got := p.Sprint(&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}})
+	expected := "[\x1b[33m1\x1b[0m, \x1b[32m\"a\"\x1b[0m]"
+	if got != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, got)
+	}
+}
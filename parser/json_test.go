@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/lexer"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		"let x = 5; let y = x * (2 + -3);",
+		"return x != y;",
+		`let f = fn(a, b) { if (a < b) { return "less" } else { a } }; f(1, 2)`,
+		`let h = {"one": 1, true: [1, 2, 3][0], 2: fn() {}}; h["one"]`,
+		`let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) };`,
+		`let f = fn(a, b = a * 2, ...rest) { g(...rest, b) }; let m = macro(x, ...xs) { quote(unquote_splice(xs)) };`,
+		`let [a, {b, c: [d, ...e]}] = x; let f = fn([p], {q: r} = y) { p };`,
+		`let m = import "math"; export let pi = m["pi"];`,
+		"if (x) { 1 }",
+		`let v = null; v?.["a"]?.[0] ?? f?.(v)`,
+		`h.a?.b.upper()`,
+		`let r = 2.5 * -0.75;`,
+		`s[1:]; s[:-1]; s[::2]; s?.[a:b:c]`,
+		`"Hello ${user.name}, ${len(items)}!"`,
+		`match (x) { 0 => "zero", -1 => "minus one", [a, ...r] if a > 0 => r, {k: [_, b]} => b, _ => x }`,
+	}
+
+	seen := map[string]bool{}
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("%q: %s", input, err)
+		}
+		var tree interface{}
+		if err := json.Unmarshal(data, &tree); err != nil {
+			t.Fatalf("%q: %s", input, err)
+		}
+		collectKinds(tree, seen)
+		decoded, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("%q: %s\n%s", input, err, data)
+		}
+		if decoded.String() != program.String() {
+			t.Errorf("%q: expected %q after the round trip, got %q", input, program.String(), decoded.String())
+		}
+		// positions survive too, so encoding again gives the same JSON
+		again, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Fatalf("%q: %s", input, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("%q: JSON is not stable\nfirst:  %s\nsecond: %s", input, data, again)
+		}
+		if !reflect.DeepEqual(decoded.Pos(), program.Pos()) {
+			t.Errorf("%q: expected the program at %s, got %s", input, program.Pos(), decoded.Pos())
+		}
+	}
+
+	// a new kind of node needs an input here, so its round trip is tested too
+	for _, kind := range ast.Kinds() {
+		if !seen[kind] {
+			t.Errorf("no test input holds a %s", kind)
+		}
+	}
+}
+
+// collectKinds records the kind of every node in the JSON form of a tree
+func collectKinds(v interface{}, seen map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if kind, ok := v["kind"].(string); ok {
+			seen[kind] = true
+		}
+		for _, child := range v {
+			collectKinds(child, seen)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectKinds(child, seen)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	p := New(lexer.New("let x = 1 + y;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"kind":"Program","statements":[{"kind":"LetStatement","pos":{"offset":0,"line":1,"column":1},` +
+		`"name":{"kind":"Identifier","pos":{"offset":4,"line":1,"column":5},"value":"x"},` +
+		`"value":{"kind":"InfixExpression","pos":{"offset":10,"line":1,"column":11},"operator":"+",` +
+		`"left":{"kind":"IntegerLiteral","pos":{"offset":8,"line":1,"column":9},"value":1},` +
+		`"right":{"kind":"Identifier","pos":{"offset":12,"line":1,"column":13},"value":"y"}}}]}`
+	if string(data) != expected {
+		t.Errorf("wrong JSON\nexpected: %s\ngot:      %s", expected, data)
+	}
+}
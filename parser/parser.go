@@ -13,6 +13,7 @@ import (
 const (
 	_ int = iota // we use a blank identifier so the other values will be typed as int
 	LOWEST
+	NULLISH     // ??
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -24,6 +25,7 @@ const (
 
 // associate token types with their precedence
 var precedences = map[token.TokenType]int{
+	token.NULLISH:  NULLISH,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -34,6 +36,16 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX, // hightest precedence
+	token.OPTIONAL: INDEX,
+	token.DOT:      INDEX,
+}
+
+// Precedence returns how tightly an infix operator binds, LOWEST if t is not an infix operator
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 type (
@@ -41,13 +53,23 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression // passed argument is the "left-side" of the infix operator
 )
 
+// Error is a parse error and where in the source it was found
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string { return e.Pos.String() + ": " + e.Msg }
+
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*Error
 
 	curToken  token.Token
 	peekToken token.Token
 
+	blocks int // how many blocks the current token is inside, export being allowed in none
+
 	// use these to check if the token has as prefix/infix parsing function
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -62,30 +84,48 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) Errors() []string {
+	msgs := []string{}
+	for _, err := range p.errors {
+		msgs = append(msgs, err.Msg)
+	}
+	return msgs
+}
+
+// ErrorList returns the parse errors together with their positions
+func (p *Parser) ErrorList() []*Error {
 	return p.errors
 }
 
+func (p *Parser) addError(pos token.Position, format string, a ...interface{}) {
+	p.errors = append(p.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*Error{},
 	}
 
 	// register our parse functions
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE, p.parseTemplate)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -98,6 +138,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
+	p.registerInfix(token.OPTIONAL, p.parseOptionalExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -136,9 +179,15 @@ func (p *Parser) ParseProgram() *ast.Program {
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		// avoid wrapping a nil *ast.LetStatement in a non-nil interface
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -146,11 +195,17 @@ func (p *Parser) parseStatement() ast.Statement {
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if stmt.Pattern = p.parsePattern(); stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
-
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) { // Note: we simply skip the ASSIGN tokens
 		return nil
 	}
@@ -163,6 +218,166 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parsePattern parses the target of a binding starting at the current token: a name, or
+// an array or hash pattern taking a value apart
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parsePattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parsePattern)
+	}
+	p.addError(p.curToken.Pos, "expected a name or a pattern, got '%s' instead", p.curToken.Type)
+	return nil
+}
+
+// parseArrayPattern parses [a, [b, c], ...rest], calling element for each element
+func (p *Parser) parseArrayPattern(element func() ast.Expression) ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return pattern
+	}
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+		el := element()
+		if el == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, el)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken() // skip the comma
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+// parseHashPattern parses {name, address: {city}}, calling element for each value
+func (p *Parser) parseHashPattern(element func() ast.Expression) ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	values := []ast.Expression{}
+	nested := false
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		var value ast.Expression
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if value = element(); value == nil {
+				return nil
+			}
+			nested = true
+		}
+		values = append(values, value)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	if nested {
+		pattern.Values = values
+	}
+	return pattern
+}
+
+// parseMatchPattern parses the pattern of a match arm, which is a binding pattern that
+// may also hold literals: 0, -1, 1.5, "a", true, null, [0, x], {kind: "circle", radius}
+func (p *Parser) parseMatchPattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		return p.prefixParseFns[p.curToken.Type]()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+			break
+		}
+		return p.parsePrefixExpression()
+	case token.LBRACKET:
+		return p.parseArrayPattern(p.parseMatchPattern)
+	case token.LBRACE:
+		return p.parseHashPattern(p.parseMatchPattern)
+	}
+	p.addError(p.curToken.Pos, "expected a pattern, got '%s' instead", p.curToken.Type)
+	return nil
+}
+
+// parseMatchExpression parses match (subject) { pattern if guard => body, ... }
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken, Arms: []*ast.MatchArm{}}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := &ast.MatchArm{}
+		if arm.Pattern = p.parseMatchPattern(); arm.Pattern == nil {
+			return nil
+		}
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		arm.Token = p.curToken
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return expression
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	// a module only exports the statements at its top level
+	if p.blocks > 0 {
+		p.addError(p.curToken.Pos, "export is only allowed at the top level of a module")
+	}
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -212,21 +427,54 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+	return &ast.FloatLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseTemplate parses "text ${expression} text". The lexer hands over the source of each
+// expression, which is parsed by a parser of its own and must hold exactly one expression.
+func (p *Parser) parseTemplate() ast.Expression {
+	template := &ast.InterpolatedString{Token: p.curToken}
+	for _, part := range p.curToken.Parts {
+		if part.Type == token.STRING {
+			template.Parts = append(template.Parts, &ast.StringLiteral{Token: part, Value: part.Literal})
+			continue
+		}
+		sub := New(lexer.NewAt(part.Literal, part.Pos))
+		if sub.curTokenIs(token.EOF) {
+			p.addError(part.Pos, "expected an expression in ${}")
+			continue
+		}
+		exp := sub.parseExpression(LOWEST)
+		if len(sub.errors) == 0 && !sub.peekTokenIs(token.EOF) {
+			sub.addError(sub.peekToken.Pos, "expected '}' after the expression in ${}, got '%s' instead", sub.peekToken.Literal)
+		}
+		p.errors = append(p.errors, sub.errors...)
+		if exp != nil {
+			template.Parts = append(template.Parts, exp)
+		}
+	}
+	return template
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -256,6 +504,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 	exp := p.parseExpression(LOWEST) // by lowering the precendence we can keep the group "together"
@@ -293,6 +545,8 @@ func (p *Parser) parseIfExpression() ast.Expression {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.blocks++
+	defer func() { p.blocks-- }()
 
 	p.nextToken()
 
@@ -312,7 +566,8 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	params := p.parseFunctionParameters()
+	lit.Parameters, lit.Patterns, lit.Defaults, lit.Rest = params.names, params.patterns, params.defaults, params.rest
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -321,35 +576,91 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parameters is a parsed parameter list. patterns and defaults are parallel to names and
+// nil if no parameter has one.
+type parameters struct {
+	names    []*ast.Identifier // nil where the parameter is a pattern
+	patterns []ast.Expression
+	defaults []ast.Expression
+	rest     *ast.Identifier
+}
+
+// parseFunctionParameters parses a parameter list, in which parameters may be patterns
+// or have default values and the last may collect the remaining arguments:
+// (a, [b, c], d = 2, ...rest)
+func (p *Parser) parseFunctionParameters() parameters {
+	params := parameters{names: []*ast.Identifier{}}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return params
 	}
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-	for p.peekTokenIs(token.COMMA) {
+	// set pads a parallel list with nils up to the current parameter
+	set := func(list *[]ast.Expression, exp ast.Expression) {
+		for len(*list) < len(params.names)-1 {
+			*list = append(*list, nil)
+		}
+		*list = append(*list, exp)
+	}
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return parameters{}
+			}
+			params.rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+		if p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.LBRACE) {
+			pattern := p.parsePattern()
+			if pattern == nil {
+				return parameters{}
+			}
+			params.names = append(params.names, nil)
+			set(&params.patterns, pattern)
+		} else {
+			params.names = append(params.names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		}
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			set(&params.defaults, p.parseExpression(LOWEST))
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken() // skip the comma
-		p.nextToken() // get our next identifier
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+	}
+	for params.patterns != nil && len(params.patterns) < len(params.names) {
+		params.patterns = append(params.patterns, nil)
+	}
+	for params.defaults != nil && len(params.defaults) < len(params.names) {
+		params.defaults = append(params.defaults, nil)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return parameters{}
 	}
-	return identifiers
+	return params
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseList(token.RPAREN, p.parseArgument)
 	return exp
 }
 
+// parseArgument parses an argument of a call, which may spread an array: f(...args)
+func (p *Parser) parseArgument() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -369,22 +680,15 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be '%s', got '%s' instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken.Pos, "expected next token to be '%s', got '%s' instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.peekToken.Type)
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
@@ -395,6 +699,12 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 // parseExpressionList is a general way to pull expressions from constructs like function parameters and array literals
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	return p.parseList(end, func() ast.Expression { return p.parseExpression(LOWEST) })
+}
+
+// parseList parses comma separated elements up to end, calling element with the current
+// token on the first token of each
+func (p *Parser) parseList(end token.TokenType, element func() ast.Expression) []ast.Expression {
 	list := []ast.Expression{}
 
 	if p.peekTokenIs(end) {
@@ -403,11 +713,11 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, element())
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, element())
 	}
 
 	if !p.expectPeek(end) {
@@ -416,16 +726,82 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseIndexExpression parses a[index], or a slice such as a[start:end:step] in which
+// any of the three may be left out
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+	if !p.peekTokenIs(token.COLON) {
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+	}
+
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: index}
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	slice.End = p.parseSliceBound()
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		slice.Step = p.parseSliceBound()
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	return slice
+}
+
+// parseSliceBound parses the expression following the current ':' of a slice, or returns
+// nil if it is left out
+func (p *Parser) parseSliceBound() ast.Expression {
+	if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.RBRACKET) {
+		return nil
+	}
+	p.nextToken()
+	return p.parseExpression(LOWEST)
+}
+
+// parseMemberExpression parses h.key, which a following call turns into a method call
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left, Optional: p.curTokenIs(token.OPTIONAL)}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	return exp
 }
 
+// parseOptionalExpression parses the field, index or call following a '?.': a?.key,
+// a?.[k] or f?.(x)
+func (p *Parser) parseOptionalExpression(left ast.Expression) ast.Expression {
+	switch {
+	case p.peekTokenIs(token.IDENT):
+		return p.parseMemberExpression(left)
+	case p.peekTokenIs(token.LBRACKET):
+		p.nextToken()
+		switch exp := p.parseIndexExpression(left).(type) {
+		case *ast.IndexExpression:
+			exp.Optional = true
+			return exp
+		case *ast.SliceExpression:
+			exp.Optional = true
+			return exp
+		}
+		return nil
+	case p.peekTokenIs(token.LPAREN):
+		p.nextToken()
+		exp := p.parseCallExpression(left).(*ast.CallExpression)
+		exp.Optional = true
+		return exp
+	}
+	p.addError(p.peekToken.Pos, "expected a name, '[' or '(' after '?.', got '%s' instead", p.peekToken.Type)
+	return nil
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -454,10 +830,32 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	params := p.parseFunctionParameters()
+	lit.Parameters, lit.Rest = params.names, params.rest
+	for _, pattern := range params.patterns {
+		if pattern != nil {
+			p.addError(pattern.Pos(), "only functions can have patterns as parameters")
+			break
+		}
+	}
+	for _, def := range params.defaults {
+		if def != nil {
+			p.addError(def.Pos(), "only functions can have default values")
+			break
+		}
+	}
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 	lit.Body = p.parseBlockStatement()
 	return lit
 }
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	exp.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/josh-weston/go_interpreter/ast"
@@ -704,6 +705,255 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultRestAndSpreadParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2) { a + b }", "fn(a, b = 2)(a + b)"},
+		{"fn(a = 1, b = a * 2) { b }", "fn(a = 1, b = (a * 2))b"},
+		{"fn(a, ...rest) { rest }", "fn(a, ...rest)rest"},
+		{"fn(a = 1, ...rest) { rest }", "fn(a = 1, ...rest)rest"},
+		{"f(...args)", "f(...args)"},
+		{"f(1, ...[2, 3], ...g(x))", "f(1, ...[2, 3], ...g(x))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	function := New(lexer.New("fn(a, b = 2, c) {}")).ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Defaults) != 3 || function.Defaults[0] != nil || function.Defaults[2] != nil {
+		t.Fatalf("expected a default for the second parameter only, got %v", function.Defaults)
+	}
+	testIntegerLiteral(t, function.Defaults[1], 2)
+}
+
+func TestPatternParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = x;", "let [a, b] = x;"},
+		{"let [a, ...rest] = x;", "let [a, ...rest] = x;"},
+		{"let [] = x;", "let [] = x;"},
+		{"let {name, age} = person;", "let {name, age} = person;"},
+		{"let {name: n, address: {city}} = person;", "let {name: n, address: {city}} = person;"},
+		{"let [a, {b, c: [d, ...e]}] = x;", "let [a, {b, c: [d, ...e]}] = x;"},
+		{"fn([a, b], {c}, d) { a }", "fn([a, b], {c}, d)a"},
+		{"fn([a, b] = [1, 2], ...rest) { a }", "fn([a, b] = [1, 2], ...rest)a"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	let := New(lexer.New("let {a, b: [c, ...d]} = x;")).ParseProgram().Statements[0].(*ast.LetStatement)
+	if let.Name != nil {
+		t.Errorf("a pattern let should have no name, got %s", let.Name)
+	}
+	names := []string{}
+	for _, ident := range ast.PatternIdentifiers(let.Pattern) {
+		names = append(names, ident.Value)
+	}
+	if strings.Join(names, " ") != "a c d" {
+		t.Errorf("expected the pattern to bind a c d, got %v", names)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, 1] = x;", "1:9: expected a name or a pattern, got 'INT' instead"},
+		{"let [...a, b] = x;", "1:10: expected next token to be ']', got ',' instead"},
+		{"let {1} = x;", "1:6: expected next token to be 'IDENT', got 'INT' instead"},
+		{"let {a b} = x;", "1:8: expected next token to be ',', got 'IDENT' instead"},
+		{"macro([a]) { a }", "1:7: only functions can have patterns as parameters"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errs := p.ErrorList(); len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestSliceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:3]", "(a[1:3])"},
+		{"a[:5]", "(a[:5])"},
+		{"a[2:]", "(a[2:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[1:-1:x + 1]", "(a[1:(-1):(x + 1)])"},
+		{"a[::]", "(a[:])"},
+		{"a?.[1:][0]", "((a?.[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	slice := New(lexer.New("a[:n]")).ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SliceExpression)
+	if slice.Start != nil || slice.End.String() != "n" || slice.Step != nil {
+		t.Errorf("wrong bounds: %v %v %v", slice.Start, slice.End, slice.Step)
+	}
+
+	p := New(lexer.New("a[1:2:3:4]"))
+	p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) == 0 || errs[0].Error() != "1:8: expected next token to be ']', got ':' instead" {
+		t.Errorf("expected an error for a fourth bound, got %v", errs)
+	}
+}
+
+func TestTemplateParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello ${user.name}, you have ${len(items)} items"`, "Hello ${(user.name)}, you have ${len(items)} items"},
+		{`"${a + b * c}"`, "${(a + (b * c))}"},
+		{`"${"inner ${x}"}!"`, "${inner ${x}}!"},
+		{`"${a}" + "b"`, "(${a} + b)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	template := New(lexer.New(`"n=${n}"`)).ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InterpolatedString)
+	if len(template.Parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(template.Parts))
+	}
+	if text, ok := template.Parts[0].(*ast.StringLiteral); !ok || text.Value != "n=" {
+		t.Errorf("wrong text part: %#v", template.Parts[0])
+	}
+	if ident, ok := template.Parts[1].(*ast.Identifier); !ok || ident.Value != "n" || ident.Pos().Column != 6 {
+		t.Errorf("wrong expression part: %#v", template.Parts[1])
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${}"`, "1:6: expected an expression in ${}"},
+		{`"a ${x y}"`, "1:8: expected '}' after the expression in ${}, got 'y' instead"},
+		{"\n  \"${1 + }\"", "2:10: no prefix parse function for EOF found"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errs := p.ErrorList(); len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: expected the error %q, got %v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestNullAndOptionalParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"null", "null"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b + 1", "(a ?? (b + 1))"},
+		{`a?.["b"]?.[0]`, "((a?.[b])?.[0])"},
+		{"f?.(1, 2)(3)", "f?.(1, 2)(3)"},
+		{"-a?.[0]", "(-(a?.[0]))"},
+		{"h?.[k] ?? null", "((h?.[k]) ?? null)"},
+		{"match (x) { null => 0, _ => 1 }", "match (x) { null => 0, _ => 1 }"},
+		{"h.a.b", "((h.a).b)"},
+		{"h?.a.b(1)", "((h?.a).b)(1)"},
+		{"-s.len() + 1", "((-(s.len)()) + 1)"},
+		{`m.f(x)["k"]`, "((m.f)(x)[k])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("a?.1"))
+	p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) == 0 || errs[0].Error() != "1:4: expected a name, '[' or '(' after '?.', got 'INT' instead" {
+		t.Errorf("expected an error for a?.1, got %v", errs)
+	}
+}
+
+func TestMatchParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 => a, _ => b }", "match (x) { 1 => a, _ => b }"},
+		{`match (x + 1) { -1 => "neg", "s" => true, true => false, }`, "match ((x + 1)) { (-1) => neg, s => true, true => false }"},
+		{"match (x) { [a, ...rest] if a > 0 => rest, [] => [] }", "match (x) { [a, ...rest] if (a > 0) => rest, [] => [] }"},
+		{`match (p) { {kind: "circle", r} => r * r, {kind: k} => k }`, "match (p) { {kind: circle, r} => (r * r), {kind: k} => k }"},
+		{"match (x) { 1.5 => a, -0.5 => b, _ => c }", "match (x) { 1.5 => a, (-0.5) => b, _ => c }"},
+		{"match (x) {}", "match (x) {  }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	match := New(lexer.New("match (x) { [a, _] if a => a }")).ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if len(match.Arms) != 1 || match.Arms[0].Guard == nil || match.Arms[0].Body.String() != "a" {
+		t.Fatalf("wrong arms: %v", match.Arms)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { a + 1 => a }", "1:15: expected next token to be '=>', got '+' instead"},
+		{"match (x) { (a) => a }", "1:13: expected a pattern, got '(' instead"},
+		{"match (x) { 1 => a 2 => b }", "1:20: expected next token to be ',', got 'INT' instead"},
+		{"match x { _ => 1 }", "1:7: expected next token to be '(', got 'IDENT' instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errs := p.ErrorList(); len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 	l := lexer.New(input)
@@ -794,6 +1044,24 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	p := New(lexer.New("2.50;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 2.5 {
+		t.Errorf("literal.Value not %v. got=%v", 2.5, literal.Value)
+	}
+	if literal.TokenLiteral() != "2.50" {
+		t.Errorf("literal.TokenLiteral not %q. got=%q", "2.50", literal.TokenLiteral())
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"hello world"`
 
@@ -1042,3 +1310,119 @@ func TestMacroLiteralParsing(t *testing.T) {
 
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
+
+func TestMacroRestParameter(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedRest   string
+	}{
+		{"macro(...args) { 1 }", []string{}, "args"},
+		{"macro(a, b, ...rest) { 1 }", []string{"a", "b"}, "rest"},
+		{"macro(a) { 1 }", []string{"a"}, ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		macro := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MacroLiteral)
+		if len(macro.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("%q: expected %d parameters, got %d", tt.input, len(tt.expectedParams), len(macro.Parameters))
+		}
+		for i, name := range tt.expectedParams {
+			testLiteralExpression(t, macro.Parameters[i], name)
+		}
+		if tt.expectedRest == "" {
+			if macro.Rest != nil {
+				t.Errorf("%q: expected no rest parameter, got %s", tt.input, macro.Rest)
+			}
+		} else if macro.Rest == nil || macro.Rest.Value != tt.expectedRest {
+			t.Errorf("%q: expected rest parameter %s, got %v", tt.input, tt.expectedRest, macro.Rest)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"macro(...rest, a) { 1 }", "1:14: expected next token to be ')', got ',' instead"},
+		{"macro(...) { 1 }", "1:10: expected next token to be 'IDENT', got ')' instead"},
+		{"macro(a, b = 1) { 1 }", "1:14: only functions can have default values"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errs := p.ErrorList(); len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestImportAndExportParsing(t *testing.T) {
+	input := `export let math = import "lib/math";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	export, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	if !testLetStatement(t, export.Statement, "math") {
+		return
+	}
+	imp, ok := export.Statement.Value.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("value is not ast.ImportExpression. got=%T", export.Statement.Value)
+	}
+	if imp.Path.Value != "lib/math" {
+		t.Errorf("path is not %q. got=%q", "lib/math", imp.Path.Value)
+	}
+	if program.String() != `export let math = import "lib/math";` {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	for _, input := range []string{
+		"if (x) { export let a = 1; }",
+		"let f = fn() {\n  export let a = 1;\n};",
+		`"${fn() { export let a = 1 }}"`,
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		errs := p.Errors()
+		if len(errs) != 1 || errs[0] != "export is only allowed at the top level of a module" {
+			t.Errorf("%q: expected an error for the nested export, got %v", input, errs)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := `let x = 5;
+let = 10;
+let y 3;`
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	errors := p.ErrorList()
+	if len(errors) < 2 {
+		t.Fatalf("expected at least 2 errors. got=%d", len(errors))
+	}
+	if errors[0].Pos.Line != 2 || errors[0].Pos.Column != 5 {
+		t.Errorf("first error at wrong position. got=%s", errors[0].Pos)
+	}
+	if errors[0].Error() != "2:5: expected next token to be 'IDENT', got '=' instead" {
+		t.Errorf("wrong error. got=%q", errors[0].Error())
+	}
+	last := errors[len(errors)-1]
+	if last.Pos.Line != 3 || last.Pos.Column != 7 {
+		t.Errorf("last error at wrong position. got=%s (%s)", last.Pos, last.Msg)
+	}
+}
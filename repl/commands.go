@@ -0,0 +1,206 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/object"
+	"github.com/josh-weston/go_interpreter/parser"
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+type command struct {
+	args string // shown in :help
+	help string
+	run  func(s *session, arg string)
+}
+
+var commands map[string]command
+
+// assigned in init because :help refers back to the table
+func init() {
+	commands = map[string]command{
+		"tokens": {"<src>", "print the tokens produced by the lexer", (*session).tokensCommand},
+		"ast":    {"<src>", "print the parsed and macro-expanded syntax tree", (*session).astCommand},
+		"env":    {"", "list the bindings in the global environment", (*session).envCommand},
+		"macros": {"", "list the macros defined in this session", (*session).macrosCommand},
+		"reset":  {"", "discard all bindings, macros and the transcript", (*session).resetCommand},
+		"load":   {"<file>", "evaluate a file in the current session", (*session).loadCommand},
+		"save":   {"<file>", "write the session transcript to a file", (*session).saveCommand},
+		"time":   {"<expr>", "evaluate an expression and report how long it took", (*session).timeCommand},
+		"debug":  {"<file|src>", "run a program under the debugger, type help at its prompt", (*session).debugCommand},
+		"help":   {"", "show this message", (*session).helpCommand},
+	}
+}
+
+// command runs a line of the form ":name argument"
+func (s *session) command(line string) {
+	name, arg := line[1:], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i+1:])
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, type :help for a list of commands\n", name)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+func (s *session) tokensCommand(src string) {
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-10s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) astCommand(src string) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+	// macros defined in src are visible while expanding it but are not kept
+	macroEnv := object.NewEnclosedEnvironment(s.macroEnv)
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, errs := evaluator.ExpandMacros(program, macroEnv)
+	if len(errs) != 0 {
+		printMacroErrors(s.out, errs)
+		return
+	}
+	dumpNode(s.out, expanded, "", "")
+}
+
+func (s *session) envCommand(string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, s.printer.Sprint(val))
+	}
+}
+
+func (s *session) macrosCommand(string) {
+	for _, name := range s.macroEnv.Names() {
+		val, _ := s.macroEnv.Get(name)
+		macro, ok := val.(*object.Macro)
+		if !ok {
+			continue
+		}
+		params := []string{}
+		for _, p := range macro.Parameters {
+			params = append(params, p.String())
+		}
+		if macro.Rest != nil {
+			params = append(params, "..."+macro.Rest.String())
+		}
+		fmt.Fprintf(s.out, "%s(%s)\n", name, strings.Join(params, ", "))
+	}
+}
+
+func (s *session) resetCommand(string) {
+	s.reset()
+	fmt.Fprintln(s.out, "session reset")
+}
+
+func (s *session) loadCommand(file string) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	s.run(string(src))
+}
+
+func (s *session) saveCommand(file string) {
+	transcript := strings.Join(s.transcript, "\n")
+	if transcript != "" {
+		transcript += "\n"
+	}
+	if err := os.WriteFile(file, []byte(transcript), 0644); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "saved %d entries to %s\n", len(s.transcript), file)
+}
+
+func (s *session) timeCommand(src string) {
+	start := time.Now()
+	evaluated, ok := s.eval(src)
+	elapsed := time.Since(start)
+	if !ok {
+		return
+	}
+	if evaluated != nil {
+		fmt.Fprintln(s.out, s.printer.Sprint(evaluated))
+	}
+	fmt.Fprintf(s.out, "took %s\n", elapsed)
+}
+
+func (s *session) helpCommand(string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "  %-16s %s\n", strings.TrimSpace(":"+name+" "+cmd.args), cmd.help)
+	}
+}
+
+// dumpNode prints node as an indented tree, one line per node, using reflection
+// so that every node type is covered without a type switch to maintain
+func dumpNode(out io.Writer, node ast.Node, indent, label string) {
+	v := reflect.ValueOf(node).Elem()
+	t := v.Type()
+
+	var scalars []string
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String, reflect.Int64, reflect.Bool:
+			scalars = append(scalars, fmt.Sprintf("%s=%#v", t.Field(i).Name, field.Interface()))
+		}
+	}
+	line := indent + label + t.Name()
+	if len(scalars) > 0 {
+		line += " " + strings.Join(scalars, " ")
+	}
+	fmt.Fprintln(out, line)
+
+	indent += "  "
+	for i := 0; i < t.NumField(); i++ {
+		field, name := v.Field(i), t.Field(i).Name
+		switch field.Kind() {
+		case reflect.Interface, reflect.Ptr:
+			if child, ok := field.Interface().(ast.Node); ok && !field.IsNil() {
+				dumpNode(out, child, indent, name+": ")
+			}
+		case reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				if child, ok := field.Index(j).Interface().(ast.Node); ok && !field.Index(j).IsNil() {
+					dumpNode(out, child, indent, fmt.Sprintf("%s[%d]: ", name, j))
+				}
+			}
+		case reflect.Map:
+			hash, ok := node.(*ast.HashLiteral)
+			if !ok || name != "Pairs" {
+				continue
+			}
+			// in source order, rather than the random order of the map
+			for _, key := range hash.Keys() {
+				dumpNode(out, key, indent, name+" key: ")
+				dumpNode(out, hash.Pairs[key], indent+"  ", "value: ")
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/debugger"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+const DEBUG_PROMPT = "(debug) "
+
+// debugOp is a command understood at the debug prompt
+type debugOp struct {
+	args string // shown in help
+	help string
+	// run reports whether the program was resumed
+	run func(s *session, d *debugger.Debugger, arg string) bool
+}
+
+var debugOps map[string]debugOp
+
+// short forms of the debug commands
+var debugAliases = map[string]string{
+	"b":  "break",
+	"c":  "continue",
+	"s":  "step",
+	"n":  "next",
+	"o":  "out",
+	"bt": "stack",
+	"l":  "locals",
+	"p":  "print",
+	"q":  "quit",
+	"h":  "help",
+}
+
+func init() {
+	debugOps = map[string]debugOp{
+		"break":    {"<line>", "stop before the statements on a line", (*session).breakCommand},
+		"clear":    {"<line>", "remove the breakpoint on a line", (*session).clearCommand},
+		"continue": {"", "run until the next breakpoint", resumeWith((*debugger.Debugger).Continue)},
+		"step":     {"", "run to the next statement, entering calls", resumeWith((*debugger.Debugger).StepIn)},
+		"next":     {"", "run to the next statement of this function", resumeWith((*debugger.Debugger).StepOver)},
+		"out":      {"", "run until this function returns", resumeWith((*debugger.Debugger).StepOut)},
+		"stack":    {"", "show the call stack", (*session).stackCommand},
+		"locals":   {"", "show the environments visible from the current statement", (*session).localsCommand},
+		"print":    {"<expr>", "evaluate an expression in the current function", (*session).printCommand},
+		"quit":     {"", "abort the program", (*session).quitCommand},
+		"help":     {"", "show this message", (*session).debugHelpCommand},
+	}
+}
+
+func resumeWith(step func(*debugger.Debugger)) func(*session, *debugger.Debugger, string) bool {
+	return func(_ *session, d *debugger.Debugger, _ string) bool {
+		step(d)
+		return true
+	}
+}
+
+// debugCommand runs a file, or failing that src itself, under the debugger. The program
+// stops before its first statement and the session's bindings are visible to it.
+func (s *session) debugCommand(arg string) {
+	src := arg
+	if data, err := os.ReadFile(arg); err == nil {
+		src = string(data)
+	}
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, errs := evaluator.ExpandMacros(program, s.macroEnv)
+	if len(errs) != 0 {
+		printMacroErrors(s.out, errs)
+		return
+	}
+
+	lines := strings.Split(src, "\n")
+	d := debugger.New()
+	d.StopOnEntry = true
+	d.Run(expanded, s.env)
+	for ev := range d.Events() {
+		switch ev := ev.(type) {
+		case *debugger.Stopped:
+			line := ""
+			if ev.Pos.Line <= len(lines) {
+				line = strings.TrimSpace(lines[ev.Pos.Line-1])
+			}
+			fmt.Fprintf(s.out, "stopped at line %d (%s): %s\n", ev.Pos.Line, ev.Reason, line)
+			s.debugPrompt(d)
+		case *debugger.Exited:
+			if ev.Result == debugger.ErrTerminated {
+				fmt.Fprintln(s.out, "program terminated")
+			} else if ev.Result != nil {
+				fmt.Fprintln(s.out, s.printer.Sprint(ev.Result))
+			}
+			return
+		}
+	}
+}
+
+// debugPrompt reads debug commands until one of them resumes the program
+func (s *session) debugPrompt(d *debugger.Debugger) {
+	for {
+		input, err := s.rl.ReadLine(DEBUG_PROMPT)
+		if err == io.EOF {
+			d.Terminate()
+			return
+		}
+		input = strings.TrimSpace(input)
+		if err != nil || input == "" {
+			continue
+		}
+		name, arg := input, ""
+		if i := strings.IndexAny(input, " \t"); i >= 0 {
+			name, arg = input[:i], strings.TrimSpace(input[i+1:])
+		}
+		if full, ok := debugAliases[name]; ok {
+			name = full
+		}
+		cmd, ok := debugOps[name]
+		if !ok {
+			fmt.Fprintf(s.out, "unknown debug command %s, type help for a list of commands\n", name)
+			continue
+		}
+		if cmd.run(s, d, arg) {
+			return
+		}
+	}
+}
+
+func parseLine(s *session, arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Fprintf(s.out, "invalid line number %q\n", arg)
+		return 0, false
+	}
+	return line, true
+}
+
+func (s *session) breakCommand(d *debugger.Debugger, arg string) bool {
+	if arg == "" {
+		for _, line := range d.Breakpoints() {
+			fmt.Fprintf(s.out, "breakpoint at line %d\n", line)
+		}
+		return false
+	}
+	if line, ok := parseLine(s, arg); ok {
+		d.SetBreakpoints(append(d.Breakpoints(), line))
+		fmt.Fprintf(s.out, "breakpoint set at line %d\n", line)
+	}
+	return false
+}
+
+func (s *session) clearCommand(d *debugger.Debugger, arg string) bool {
+	line, ok := parseLine(s, arg)
+	if !ok {
+		return false
+	}
+	lines := []int{}
+	for _, bp := range d.Breakpoints() {
+		if bp != line {
+			lines = append(lines, bp)
+		}
+	}
+	d.SetBreakpoints(lines)
+	return false
+}
+
+func (s *session) stackCommand(d *debugger.Debugger, _ string) bool {
+	for i, frame := range d.Stack() {
+		fmt.Fprintf(s.out, "#%d %s at line %d\n", i, frame.Name, frame.Pos.Line)
+	}
+	return false
+}
+
+func (s *session) localsCommand(d *debugger.Debugger, _ string) bool {
+	scopes := debugger.Scopes(d.Stack()[0].Env)
+	for i, scope := range scopes {
+		label := "closure"
+		switch {
+		case i == len(scopes)-1:
+			label = "globals"
+		case i == 0:
+			label = "locals"
+		}
+		fmt.Fprintf(s.out, "%s:\n", label)
+		for _, v := range scope.Variables {
+			fmt.Fprintf(s.out, "  %s = %s\n", v.Name, s.printer.Sprint(v.Value))
+		}
+	}
+	return false
+}
+
+func (s *session) printCommand(d *debugger.Debugger, arg string) bool {
+	if result := d.Evaluate(arg, 0); result != nil {
+		fmt.Fprintln(s.out, s.printer.Sprint(result))
+	}
+	return false
+}
+
+func (s *session) quitCommand(d *debugger.Debugger, _ string) bool {
+	d.Terminate()
+	return true
+}
+
+func (s *session) debugHelpCommand(*debugger.Debugger, string) bool {
+	aliases := make(map[string]string)
+	for short, full := range debugAliases {
+		aliases[full] = short
+	}
+	names := make([]string, 0, len(debugOps))
+	for name := range debugOps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := debugOps[name]
+		usage := name
+		if short, ok := aliases[name]; ok {
+			usage += " (" + short + ")"
+		}
+		fmt.Fprintf(s.out, "  %-20s %s\n", strings.TrimSpace(usage+" "+cmd.args), cmd.help)
+	}
+	return false
+}
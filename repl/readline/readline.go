@@ -0,0 +1,453 @@
+// Package readline is a small line editor for the REPL. On a terminal it offers cursor
+// movement, history with reverse search and tab completion; on any other input it
+// simply reads lines.
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupt is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupt = errors.New("interrupt")
+
+// Completer returns every candidate that could replace the word being typed
+type Completer func(prefix string) []string
+
+type Config struct {
+	HistoryFile  string // history is loaded from and saved to this file, empty disables persistence
+	HistoryLimit int    // number of entries kept in the history file, defaults to 1000
+	Completer    Completer
+}
+
+type Reader struct {
+	cfg      Config
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool // only a terminal gets line editing, history files and completion
+	history  []string
+}
+
+func New(in io.Reader, out io.Writer, cfg Config) *Reader {
+	if cfg.HistoryLimit == 0 {
+		cfg.HistoryLimit = 1000
+	}
+	r := &Reader{cfg: cfg, in: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		r.fd = int(f.Fd())
+		r.terminal = true
+		r.loadHistory()
+	}
+	return r
+}
+
+// IsTerminal reports whether w is connected to a terminal
+func IsTerminal(w interface{}) bool {
+	f, ok := w.(*os.File)
+	return ok && isTerminal(int(f.Fd()))
+}
+
+// Width returns the number of columns of the terminal behind w, or 0 if it is not a terminal
+func Width(w interface{}) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	return terminalWidth(int(f.Fd()))
+}
+
+// ReadLine prints prompt and returns the next line without its line ending. It returns
+// io.EOF once input is exhausted and ErrInterrupt if the line was abandoned with Ctrl-C.
+func (r *Reader) ReadLine(prompt string) (string, error) {
+	var line string
+	var err error
+	if r.terminal {
+		restore, rawErr := makeRaw(r.fd)
+		if rawErr != nil {
+			r.terminal = false
+			return r.ReadLine(prompt)
+		}
+		line, err = r.edit(prompt)
+		restore()
+	} else {
+		line, err = r.readPlain(prompt)
+	}
+	if err == nil {
+		r.AddHistory(line)
+	}
+	return line, err
+}
+
+func (r *Reader) readPlain(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// AddHistory records line unless it is blank or repeats the previous entry
+func (r *Reader) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+}
+
+func (r *Reader) History() []string {
+	return r.history
+}
+
+// Close saves the history file
+func (r *Reader) Close() error {
+	if !r.terminal || r.cfg.HistoryFile == "" {
+		return nil
+	}
+	history := r.history
+	if len(history) > r.cfg.HistoryLimit {
+		history = history[len(history)-r.cfg.HistoryLimit:]
+	}
+	return os.WriteFile(r.cfg.HistoryFile, []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
+
+func (r *Reader) loadHistory() {
+	if r.cfg.HistoryFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.cfg.HistoryFile)
+	if err != nil {
+		return // a missing history file is not an error
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		r.AddHistory(line)
+	}
+}
+
+// lineState is the line being edited
+type lineState struct {
+	prompt  string
+	buf     []rune
+	pos     int
+	histIdx int    // index into history, len(history) is the line being typed
+	saved   []rune // the line being typed while browsing history
+}
+
+// key codes for the control characters we handle
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	esc       = 27
+	backspace = 127
+)
+
+func (r *Reader) edit(prompt string) (string, error) {
+	st := &lineState{prompt: prompt, histIdx: len(r.history)}
+	r.refresh(st)
+	for {
+		ch, _, err := r.in.ReadRune()
+		if err != nil {
+			if len(st.buf) > 0 {
+				fmt.Fprint(r.out, "\r\n")
+				return string(st.buf), nil
+			}
+			return "", err
+		}
+
+		switch ch {
+		case enter, '\n':
+			fmt.Fprint(r.out, "\r\n")
+			return string(st.buf), nil
+		case ctrlC:
+			fmt.Fprint(r.out, "^C\r\n")
+			return "", ErrInterrupt
+		case ctrlD:
+			if len(st.buf) == 0 {
+				fmt.Fprint(r.out, "\r\n")
+				return "", io.EOF
+			}
+			st.delete()
+		case ctrlA:
+			st.pos = 0
+		case ctrlE:
+			st.pos = len(st.buf)
+		case ctrlB:
+			st.left()
+		case ctrlF:
+			st.right()
+		case ctrlH, backspace:
+			if st.pos > 0 {
+				st.pos--
+				st.delete()
+			}
+		case ctrlK:
+			st.buf = st.buf[:st.pos]
+		case ctrlU:
+			st.buf = append([]rune{}, st.buf[st.pos:]...)
+			st.pos = 0
+		case ctrlW:
+			start := st.pos
+			for start > 0 && st.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && st.buf[start-1] != ' ' {
+				start--
+			}
+			st.buf = append(st.buf[:start], st.buf[st.pos:]...)
+			st.pos = start
+		case ctrlL:
+			fmt.Fprint(r.out, "\x1b[H\x1b[2J")
+		case ctrlP:
+			r.historyPrev(st)
+		case ctrlN:
+			r.historyNext(st)
+		case ctrlR:
+			if submit := r.reverseSearch(st); submit {
+				fmt.Fprint(r.out, "\r\n")
+				return string(st.buf), nil
+			}
+		case tab:
+			r.complete(st)
+		case esc:
+			r.escape(st)
+		default:
+			if unicode.IsPrint(ch) {
+				st.insert(ch)
+			}
+		}
+		r.refresh(st)
+	}
+}
+
+// escape handles the arrow, home, end and delete key sequences
+func (r *Reader) escape(st *lineState) {
+	next, _, err := r.in.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return
+	}
+	// a CSI sequence, ESC [, has parameter bytes before its final byte: ESC [ 1 ; 5 C
+	var params []rune
+	code, _, err := r.in.ReadRune()
+	for err == nil && next == '[' && (code < 0x40 || code > 0x7e) {
+		params = append(params, code)
+		code, _, err = r.in.ReadRune()
+	}
+	if err != nil {
+		return
+	}
+	if code == '~' {
+		// sequences like ESC [ 3 ~
+		switch string(params) {
+		case "1", "7":
+			st.pos = 0
+		case "4", "8":
+			st.pos = len(st.buf)
+		case "3":
+			st.delete()
+		}
+		return
+	}
+	if len(params) > 0 {
+		return // keys with modifiers, such as Ctrl-Right, are not bound
+	}
+	switch code {
+	case 'A':
+		r.historyPrev(st)
+	case 'B':
+		r.historyNext(st)
+	case 'C':
+		st.right()
+	case 'D':
+		st.left()
+	case 'H':
+		st.pos = 0
+	case 'F':
+		st.pos = len(st.buf)
+	}
+}
+
+func (r *Reader) refresh(st *lineState) {
+	fmt.Fprintf(r.out, "\r%s%s\x1b[K", st.prompt, string(st.buf))
+	if back := len(st.buf) - st.pos; back > 0 {
+		fmt.Fprintf(r.out, "\x1b[%dD", back)
+	}
+}
+
+func (st *lineState) insert(chars ...rune) {
+	tail := append([]rune{}, st.buf[st.pos:]...)
+	st.buf = append(append(st.buf[:st.pos], chars...), tail...)
+	st.pos += len(chars)
+}
+
+// delete removes the character under the cursor
+func (st *lineState) delete() {
+	if st.pos < len(st.buf) {
+		st.buf = append(st.buf[:st.pos], st.buf[st.pos+1:]...)
+	}
+}
+
+func (st *lineState) left() {
+	if st.pos > 0 {
+		st.pos--
+	}
+}
+
+func (st *lineState) right() {
+	if st.pos < len(st.buf) {
+		st.pos++
+	}
+}
+
+func (st *lineState) set(line string) {
+	st.buf = []rune(line)
+	st.pos = len(st.buf)
+}
+
+func (r *Reader) historyPrev(st *lineState) {
+	if st.histIdx == 0 {
+		return
+	}
+	if st.histIdx == len(r.history) {
+		st.saved = append([]rune{}, st.buf...)
+	}
+	st.histIdx--
+	st.set(r.history[st.histIdx])
+}
+
+func (r *Reader) historyNext(st *lineState) {
+	if st.histIdx >= len(r.history) {
+		return
+	}
+	st.histIdx++
+	if st.histIdx == len(r.history) {
+		st.set(string(st.saved))
+		return
+	}
+	st.set(r.history[st.histIdx])
+}
+
+// reverseSearch runs an incremental search backwards through history. It reports
+// whether the found line should be submitted straight away (the user pressed enter).
+func (r *Reader) reverseSearch(st *lineState) (submit bool) {
+	original := string(st.buf)
+	query := []rune{}
+	idx := len(r.history)
+	match := ""
+
+	search := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(r.history[i], string(query)) {
+				idx, match = i, r.history[i]
+				return
+			}
+		}
+	}
+
+	for {
+		fmt.Fprintf(r.out, "\r(reverse-i-search)'%s': %s\x1b[K", string(query), match)
+		ch, _, err := r.in.ReadRune()
+		if err != nil {
+			return false
+		}
+		switch {
+		case ch == enter || ch == '\n':
+			st.set(match)
+			return true
+		case ch == ctrlG || ch == ctrlC:
+			st.set(original)
+			return false
+		case ch == ctrlR:
+			search(idx - 1)
+		case ch == backspace || ch == ctrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				search(len(r.history) - 1)
+			}
+		case unicode.IsPrint(ch):
+			query = append(query, ch)
+			search(min(idx, len(r.history)-1))
+		default:
+			// any other key accepts the match and goes back to normal editing
+			st.set(match)
+			return false
+		}
+	}
+}
+
+func isWordChar(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_' || ch == ':'
+}
+
+func (r *Reader) complete(st *lineState) {
+	if r.cfg.Completer == nil {
+		return
+	}
+	start := st.pos
+	for start > 0 && isWordChar(st.buf[start-1]) {
+		start--
+	}
+	prefix := string(st.buf[start:st.pos])
+	candidates := r.cfg.Completer(prefix)
+	if len(candidates) == 0 {
+		fmt.Fprint(r.out, "\a")
+		return
+	}
+
+	common := longestCommonPrefix(candidates)
+	if len(common) > len(prefix) {
+		st.insert([]rune(common[len(prefix):])...)
+		return
+	}
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		fmt.Fprintf(r.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+// longestCommonPrefix compares runes, so the prefix never ends inside a character
+func longestCommonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, w := range words[1:] {
+		n := 0
+		for _, ch := range w {
+			if n == len(prefix) || prefix[n] != ch {
+				break
+			}
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
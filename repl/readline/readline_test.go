@@ -0,0 +1,102 @@
+package readline
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadLinePlain(t *testing.T) {
+	var out bytes.Buffer
+	r := New(strings.NewReader("let a = 1;\r\n\nlast"), &out, Config{})
+
+	expected := []string{"let a = 1;", "", "last"}
+	for _, want := range expected {
+		got, err := r.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != want {
+			t.Errorf("wrong line. want=%q, got=%q", want, got)
+		}
+	}
+	if _, err := r.ReadLine("> "); err != io.EOF {
+		t.Errorf("expected io.EOF, got=%v", err)
+	}
+	if out.String() != "> > > > " {
+		t.Errorf("prompts not written. got=%q", out.String())
+	}
+	if len(r.History()) != 2 {
+		t.Errorf("blank lines should not be kept in history. got=%q", r.History())
+	}
+}
+
+func TestEdit(t *testing.T) {
+	completer := func(prefix string) []string {
+		var matches []string
+		for _, word := range []string{"len", "let", "last", ":load", "café", "cafè"} {
+			if strings.HasPrefix(word, prefix) {
+				matches = append(matches, word)
+			}
+		}
+		return matches
+	}
+
+	tests := []struct {
+		keys     string
+		history  []string
+		expected string
+	}{
+		{"abc\r", nil, "abc"},
+		{"ac\x1b[Db\r", nil, "abc"},                       // left arrow then insert
+		{"bc\x01a\x05d\r", nil, "abcd"},                   // ctrl-a, ctrl-e
+		{"abcd\x7f\x7f\r", nil, "ab"},                     // backspace
+		{"abcd\x1b[D\x1b[D\x0b\r", nil, "ab"},             // ctrl-k
+		{"abcd\x1b[D\x15\r", nil, "d"},                    // ctrl-u
+		{"let x = 1\x17\r", nil, "let x = "},              // ctrl-w
+		{"ab\x1b[H\x1b[3~\r", nil, "b"},                   // home, delete
+		{"\x1b[A\r", []string{"one", "two"}, "two"},       // up arrow
+		{"\x1b[A\x1b[A\r", []string{"one", "two"}, "one"}, // up twice
+		{"x\x1b[A\x1b[B\r", []string{"one"}, "x"},         // down restores the typed line
+		{"\x12on\r", []string{"one", "two", "none"}, "none"},
+		{"\x12on\x12\r", []string{"one", "two", "none"}, "one"},
+		{"abc\x12zz\x07\r", []string{"one"}, "abc"}, // ctrl-g cancels
+		{"le\t(1)\r", nil, "le(1)"},                 // ambiguous: nothing inserted
+		{"la\t\r", nil, "last"},
+		{":lo\t x\r", nil, ":load x"},
+		{"x = le\tn\r", nil, "x = len"},
+		{"ca\t\r", nil, "caf"},          // é and è share their first byte
+		{"ab\x1b[1;5Cc\r", nil, "abc"},  // ctrl-right is ignored
+		{"ab\x1b[200~c\r", nil, "abc"},  // unknown sequences are ignored
+		{"ab\x1b[D\x1b[3~\r", nil, "a"}, // delete
+		{"ab\x1bOHc\r", nil, "cab"},     // home in application mode
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		r := New(strings.NewReader(tt.keys), &out, Config{Completer: completer})
+		for _, h := range tt.history {
+			r.AddHistory(h)
+		}
+		got, err := r.edit("> ")
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.keys, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("wrong line for %q. want=%q, got=%q", tt.keys, tt.expected, got)
+		}
+	}
+}
+
+func TestEditControlKeys(t *testing.T) {
+	var out bytes.Buffer
+	r := New(strings.NewReader("abc\x03\x04"), &out, Config{})
+	if _, err := r.edit("> "); err != ErrInterrupt {
+		t.Errorf("ctrl-c should interrupt. got=%v", err)
+	}
+	if _, err := r.edit("> "); err != io.EOF {
+		t.Errorf("ctrl-d on an empty line should be io.EOF. got=%v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package readline
+
+import "errors"
+
+// without termios support we always read plain lines
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
+
+func terminalWidth(fd int) int { return 0 }
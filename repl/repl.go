@@ -1,51 +1,223 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/josh-weston/go_interpreter/evaluator"
 	"github.com/josh-weston/go_interpreter/lexer"
 	"github.com/josh-weston/go_interpreter/object"
 	"github.com/josh-weston/go_interpreter/parser"
+	"github.com/josh-weston/go_interpreter/repl/readline"
+	"github.com/josh-weston/go_interpreter/token"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. "
+)
+
+// HISTORY_FILE is the name of the history file kept in the user's home directory
+const HISTORY_FILE = ".monkey_history"
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	macroEnv := object.NewEnvironment()
+	s := newSession(out)
+	rl := readline.New(in, out, readline.Config{
+		HistoryFile: historyPath(),
+		Completer:   s.complete,
+	})
+	defer rl.Close()
+	s.rl = rl
 	for {
-		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, ok := readInput(rl)
+		if !ok {
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		if strings.HasPrefix(input, ":") {
+			s.command(input)
 			continue
 		}
+		s.run(input)
+	}
+}
+
+// session holds the state that lives for as long as the REPL is running
+type session struct {
+	rl         *readline.Reader // nil when the session is not interactive
+	out        io.Writer
+	printer    *object.Printer
+	env        *object.Environment
+	macroEnv   *object.Environment
+	transcript []string // every successfully parsed input, in order
+}
+
+func newSession(out io.Writer) *session {
+	s := &session{out: out, printer: object.NewPrinter()}
+	// colour only makes sense on a terminal, NO_COLOR lets users opt out
+	if readline.IsTerminal(out) && os.Getenv("NO_COLOR") == "" {
+		s.printer.Color = true
+	}
+	if width := readline.Width(out); width > 0 {
+		s.printer.Width = width
+	}
+	s.reset()
+	return s
+}
+
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.macroEnv = object.NewEnvironment()
+	s.transcript = nil
+}
+
+// run evaluates input in the session and prints the result
+func (s *session) run(input string) {
+	evaluated, ok := s.eval(input)
+	if ok && evaluated != nil {
+		io.WriteString(s.out, s.printer.Sprint(evaluated))
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// eval parses, expands and evaluates input. ok is false if input did not parse.
+func (s *session) eval(input string) (evaluated object.Object, ok bool) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+	s.transcript = append(s.transcript, input)
+
+	// where we expand the macros before evaluating the tree
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, errs := evaluator.ExpandMacros(program, s.macroEnv)
+	if len(errs) != 0 {
+		printMacroErrors(s.out, errs)
+		return nil, false
+	}
+
+	return evaluator.Eval(expanded, s.env), true
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// complete offers meta-commands, keywords, builtins and the names bound in the session
+func (s *session) complete(prefix string) []string {
+	var words []string
+	if strings.HasPrefix(prefix, ":") {
+		for name := range commands {
+			words = append(words, ":"+name)
+		}
+	} else {
+		words = append(words, token.Keywords()...)
+		words = append(words, evaluator.BuiltinNames()...)
+		words = append(words, s.env.Names()...)
+		words = append(words, s.macroEnv.Names()...)
+	}
 
-		// where we expand the macros before evaluating the tree
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+	seen := make(map[string]bool)
+	candidates := []string{}
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) && !seen[word] {
+			seen[word] = true
+			candidates = append(candidates, word)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
 
-		evaluated := evaluator.Eval(expanded, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+// readInput keeps reading lines until they form a complete expression. A blank
+// line submits whatever has been typed so far. ok is false once the input is exhausted.
+func readInput(rl *readline.Reader) (input string, ok bool) {
+	var lines []string
+	prompt := PROMPT
+	for {
+		line, err := rl.ReadLine(prompt)
+		if err == readline.ErrInterrupt {
+			return "", true // Ctrl-C abandons everything typed so far
+		}
+		if err != nil {
+			// submit a partially typed expression rather than silently dropping it
+			return strings.Join(lines, "\n"), len(lines) > 0
+		}
+		if strings.TrimSpace(line) == "" {
+			return strings.Join(lines, "\n"), true
 		}
+		// meta-commands always fit on a single line
+		if len(lines) == 0 && strings.HasPrefix(line, ":") {
+			return line, true
+		}
+		lines = append(lines, line)
+		input = strings.Join(lines, "\n")
+		if !isIncomplete(input) {
+			return input, true
+		}
+		prompt = CONTINUATION_PROMPT
 	}
 }
 
+// a line ending in one of these cannot be the end of an expression
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.COMMA:    true,
+	token.COLON:    true,
+	token.NULLISH:  true,
+	token.OPTIONAL: true,
+	token.DOT:      true,
+	token.ARROW:    true,
+	token.ELLIPSIS: true,
+}
+
+// isIncomplete reports whether input has unbalanced delimiters or ends with an operator
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+	return depth > 0 || continuationTokens[last.Type]
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, fmt.Sprintf("\t%s\n", msg))
 	}
 }
+
+func printMacroErrors(out io.Writer, errors []*evaluator.MacroError) {
+	for _, err := range errors {
+		io.WriteString(out, fmt.Sprintf("\t%s\n", err))
+	}
+}
@@ -0,0 +1,192 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n x + y\n};", false},
+		{"[1, 2,", true},
+		{"add(1,\n 2)", false},
+		{"5 +", true},
+		{"let x =", true},
+		{`{"a": 1}["a"]`, false},
+		{"}", false},
+		{"let x = a ??", true},
+		{"match (x) { 1 =>", true},
+		{"h.", true},
+		{"h?.", true},
+		{"f(...", true},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. want=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let add = fn(x, y) {\n  x + y\n};\nadd(1,\n2)\n",
+			">> .. .. >> .. 3\n>> ",
+		},
+		{
+			// a blank line forces submission of an unfinished expression
+			"let x =\n\n",
+			">> .. \tno prefix parse function for EOF found\n>> ",
+		},
+		{
+			"5 +\n5\n\n10\n",
+			">> .. 10\n>> >> 10\n>> ",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+		if out.String() != tt.expected {
+			t.Errorf("wrong output. want=%q, got=%q", tt.expected, out.String())
+		}
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let b = 2;\nlet a = 1;\n:env\n",
+			"a = 1\nb = 2\n",
+		},
+		{
+			":tokens let x = 1;\n",
+			"LET        \"let\"\nIDENT      \"x\"\n=          \"=\"\nINT        \"1\"\n;          \";\"\n",
+		},
+		{
+			":ast -a * b\n",
+			"Program\n  Statements[0]: ExpressionStatement\n    Expression: InfixExpression Operator=\"*\"\n" +
+				"      Left: PrefixExpression Operator=\"-\"\n        Right: Identifier Value=\"a\"\n" +
+				"      Right: Identifier Value=\"b\"\n",
+		},
+		{
+			":ast {\"b\": 1, \"a\": 2, \"c\": 3}\n",
+			"Program\n  Statements[0]: ExpressionStatement\n    Expression: HashLiteral\n" +
+				"      Pairs key: StringLiteral Value=\"b\"\n        value: IntegerLiteral Value=1\n" +
+				"      Pairs key: StringLiteral Value=\"a\"\n        value: IntegerLiteral Value=2\n" +
+				"      Pairs key: StringLiteral Value=\"c\"\n        value: IntegerLiteral Value=3\n",
+		},
+		{
+			"let m = macro(x, y) { x };\n:macros\n",
+			"m(x, y)\n",
+		},
+		{
+			":ast let m = macro() { 1 }; m()\n",
+			"\t1:24: in expansion of m: the macro must return a quote, got INTEGER\n",
+		},
+		{
+			"let a = 1;\n:reset\n:env\n",
+			"session reset\n",
+		},
+		{
+			":nope\n",
+			"unknown command :nope, type :help for a list of commands\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+		got := strings.ReplaceAll(out.String(), PROMPT, "")
+		if got != tt.expected {
+			t.Errorf("wrong output for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	file := t.TempDir() + "/session.monkey"
+	var out bytes.Buffer
+	Start(strings.NewReader("let a = 40;\nlet f = fn(x) { x + 2 };\n:save "+file+"\n"), &out)
+
+	out.Reset()
+	Start(strings.NewReader(":load "+file+"\nf(a)\n"), &out)
+	if got := strings.ReplaceAll(out.String(), PROMPT, ""); got != "42\n" {
+		t.Errorf("wrong output. want=%q, got=%q", "42\n", got)
+	}
+}
+
+func TestDebug(t *testing.T) {
+	file := t.TempDir() + "/debug.monkey"
+	src := "let add = fn(a, b) {\n  let sum = a + b;\n  sum\n};\nlet x = add(1, 2);\nx * 2\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	input := ":debug " + file + "\nbreak 3\nc\nstack\nlocals\np sum * 10\nout\nnext\nx\n"
+	Start(strings.NewReader(input), &out)
+
+	expected := []string{
+		"stopped at line 1 (entry): let add = fn(a, b) {",
+		"breakpoint set at line 3",
+		"stopped at line 3 (breakpoint): sum",
+		"#0 add at line 3\n#1 main at line 5",
+		"locals:\n  a = 1\n  b = 2\n  sum = 3\nglobals:\n  add = ",
+		"30",
+		"stopped at line 6 (step): x * 2",
+		"6\n",
+		// bindings made while debugging stay in the session
+		PROMPT + "3\n",
+	}
+	got := out.String()
+	for _, want := range expected {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestDebugQuit(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader(":debug let a = 1; a + 1\nq\n"), &out)
+	if !strings.Contains(out.String(), "program terminated") {
+		t.Errorf("expected the program to be terminated, got:\n%s", out.String())
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := newSession(&bytes.Buffer{})
+	s.run("let lengthy = 1; let rest_of = 2; let m = macro() { quote(1) };")
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"le", []string{"len", "lengthy", "let"}},
+		{"re", []string{"rest", "rest_of", "return"}},
+		{"m", []string{"m", "macro", "match"}},
+		{":l", []string{":load"}},
+		{"zz", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := s.complete(tt.prefix)
+		if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("complete(%q) wrong. want=%v, got=%v", tt.prefix, tt.expected, got)
+		}
+	}
+}
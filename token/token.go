@@ -1,10 +1,35 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // where the token starts in the source
+	Parts   []Token  // the pieces of a TEMPLATE: STRING for the text, INTERPOLATION for each ${...}
+}
+
+// Position is a location in the source. Lines and columns start at 1, a zero Position
+// means the node was not produced by the parser (e.g. by a macro expansion).
+type Position struct {
+	Offset int // byte offset
+	Line   int
+	Column int // byte column
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// IsValid reports whether the position came from source code
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// Comment is a `//` comment. Comments are not tokens, the lexer collects them on the side.
+type Comment struct {
+	Pos  Position
+	Text string // including the leading //
 }
 
 var keywords = map[string]TokenType{
@@ -12,10 +37,23 @@ var keywords = map[string]TokenType{
 	"let":    LET,
 	"true":   TRUE,
 	"false":  FALSE,
+	"null":   NULL,
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
 	"macro":  MACRO,
+	"import": IMPORT,
+	"export": EXPORT,
+	"match":  MATCH,
+}
+
+// Keywords returns every reserved word of the language
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	return words
 }
 
 func LookupIdent(ident string) TokenType {
@@ -32,8 +70,13 @@ const (
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// a string holding ${...}, and the source of the expression in each
+	TEMPLATE      = "TEMPLATE"
+	INTERPOLATION = "INTERPOLATION"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -47,11 +90,17 @@ const (
 
 	EQ     = "=="
 	NOT_EQ = "!="
+	ARROW  = "=>"
+
+	NULLISH  = "??"
+	OPTIONAL = "?." // followed by the [ or ( of an index or call
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -65,10 +114,18 @@ const (
 	LET      = "LET"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 
 	// Macros
 	MACRO = "MACRO"
+
+	// Modules
+	IMPORT = "IMPORT"
+	EXPORT = "EXPORT"
+
+	// Pattern matching
+	MATCH = "MATCH"
 )
@@ -0,0 +1,236 @@
+package vet
+
+import (
+	"strings"
+
+	"github.com/josh-weston/go_interpreter/analysis"
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/evaluator"
+	"github.com/josh-weston/go_interpreter/object"
+)
+
+// fromAnalysis reports the diagnostics of the scope analysis with the given code
+func fromAnalysis(code string) func(p *pass) {
+	return func(p *pass) {
+		for _, d := range p.info.Diagnostics {
+			if d.Code == code {
+				p.report(d.Pos, "%s", d.Msg)
+			}
+		}
+	}
+}
+
+// terminates reports whether stmt always leaves the enclosing function
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		ifExp, ok := stmt.Expression.(*ast.IfExpression)
+		return ok && ifExp.Alternative != nil && blockTerminates(ifExp.Consequence) && blockTerminates(ifExp.Alternative)
+	}
+	return false
+}
+
+func blockTerminates(block *ast.BlockStatement) bool {
+	if block == nil {
+		return false
+	}
+	for _, stmt := range block.Statements {
+		if terminates(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+// unreachable reports the first statement following one that always returns
+func unreachable(p *pass) {
+	check := func(statements []ast.Statement) {
+		for i, stmt := range statements {
+			if terminates(stmt) && i+1 < len(statements) {
+				p.report(statements[i+1].Pos(), "unreachable code")
+				return
+			}
+		}
+	}
+	ast.Inspect(p.program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			check(node.Statements)
+		case *ast.BlockStatement:
+			check(node.Statements)
+		}
+		return true
+	})
+}
+
+// constant reports whether exp is built from literals alone
+func constant(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean, *ast.NullLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return constant(exp.Right)
+	case *ast.InfixExpression:
+		return constant(exp.Left) && constant(exp.Right)
+	}
+	return false
+}
+
+// constantCondition evaluates the conditions made of literals alone
+func constantCondition(p *pass) {
+	ast.Inspect(p.program, func(node ast.Node) bool {
+		ifExp, ok := node.(*ast.IfExpression)
+		if !ok || !constant(ifExp.Condition) {
+			return true
+		}
+		// a condition that fails, such as a type mismatch or a division by zero, is not
+		// constant and is left for the evaluator to report
+		result := evaluator.Eval(ifExp.Condition, object.NewEnvironment())
+		if _, isErr := result.(*object.Error); isErr {
+			return true
+		}
+		if result == evaluator.FALSE || result == evaluator.NULL {
+			p.report(ifExp.Condition.Pos(), "condition is always false")
+		} else {
+			p.report(ifExp.Condition.Pos(), "condition is always true")
+		}
+		return true
+	})
+}
+
+// hasCall reports whether evaluating exp calls a function, which may give a
+// different result each time
+func hasCall(exp ast.Expression) bool {
+	found := false
+	ast.Inspect(exp, func(node ast.Node) bool {
+		if _, ok := node.(*ast.CallExpression); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// the result of comparing a value with itself
+var selfComparisons = map[string]string{
+	"==": "true",
+	"!=": "false",
+	"<":  "false",
+	">":  "false",
+}
+
+func selfComparison(p *pass) {
+	ast.Inspect(p.program, func(node ast.Node) bool {
+		infix, ok := node.(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+		result, ok := selfComparisons[infix.Operator]
+		if ok && infix.Left.String() == infix.Right.String() && !hasCall(infix.Left) {
+			p.report(infix.Pos(), "comparison of %s with itself is always %s", infix.Left, result)
+		}
+		return true
+	})
+}
+
+// literalType is the type of the value exp evaluates to, if it is a literal
+func literalType(exp ast.Expression) (object.ObjectType, bool) {
+	switch exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ, true
+	case *ast.FloatLiteral:
+		return object.FLOAT_OBJ, true
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		return object.STRING_OBJ, true
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ, true
+	case *ast.NullLiteral:
+		return object.NULL_OBJ, true
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ, true
+	case *ast.HashLiteral:
+		return object.HASH_OBJ, true
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ, true
+	}
+	return "", false
+}
+
+// builtinArgs checks calls to builtins against their declared parameters
+func builtinArgs(p *pass) {
+	ast.Inspect(p.program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		// only names that resolve to the builtin, not a shadowing declaration
+		binding := p.info.Refs[ident]
+		if binding == nil || binding.Kind != analysis.Builtin {
+			return true
+		}
+		builtin, ok := evaluator.LookupBuiltin(ident.Value)
+		if !ok {
+			return true
+		}
+		for _, arg := range call.Arguments {
+			if _, ok := arg.(*ast.SpreadExpression); ok {
+				return true // the arguments are only known at run time
+			}
+		}
+		if msg := builtin.CheckArity(len(call.Arguments)); msg != "" {
+			p.report(call.Pos(), "wrong number of arguments to %s: %s", ident.Value, msg)
+			return true
+		}
+		for i, arg := range call.Arguments {
+			param, _ := builtin.Param(i)
+			got, ok := literalType(arg)
+			if !ok || len(param.Types) == 0 || acceptsType(param.Types, got) {
+				continue
+			}
+			want := make([]string, len(param.Types))
+			for i, t := range param.Types {
+				want[i] = string(t)
+			}
+			p.report(arg.Pos(), "argument %s of %s must be %s, got %s", param.Name, ident.Value, strings.Join(want, " or "), got)
+		}
+		return true
+	})
+}
+
+func acceptsType(types []object.ObjectType, t object.ObjectType) bool {
+	for _, want := range types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// macroQuote reports macros that cannot produce code because they never call quote
+func macroQuote(p *pass) {
+	ast.Inspect(p.program, func(node ast.Node) bool {
+		macro, ok := node.(*ast.MacroLiteral)
+		if !ok {
+			return true
+		}
+		quoted := false
+		ast.Inspect(macro.Body, func(node ast.Node) bool {
+			if call, ok := node.(*ast.CallExpression); ok {
+				if ident, ok := call.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+					quoted = true
+				}
+			}
+			return !quoted
+		})
+		if !quoted {
+			p.report(macro.Pos(), "macro never returns quote()")
+		}
+		return true
+	})
+}
@@ -0,0 +1,138 @@
+// Package vet reports suspicious constructs in Monkey programs that parse and may well
+// run, but are probably mistakes.
+package vet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/josh-weston/go_interpreter/analysis"
+	"github.com/josh-weston/go_interpreter/ast"
+	"github.com/josh-weston/go_interpreter/token"
+)
+
+// ConfigFile is the name of the configuration looked up in the working directory
+const ConfigFile = ".monkeyvet.json"
+
+// Rule is a single check. Rules are run over the whole program and report through the pass.
+type Rule struct {
+	Name    string
+	Doc     string
+	Default bool // whether the rule runs when the configuration does not mention it
+	run     func(p *pass)
+}
+
+// Rules lists every rule in the order they are run
+var Rules []*Rule
+
+func init() {
+	Rules = []*Rule{
+		{"unreachable", "statements that follow a return", true, unreachable},
+		{"constant-condition", "if conditions that are always true or always false", true, constantCondition},
+		{"self-comparison", "comparisons of an expression with itself", true, selfComparison},
+		{"builtin-args", "calls to builtins with the wrong number or type of arguments", true, builtinArgs},
+		{"unused", "let bindings in functions that are never used", true, fromAnalysis(analysis.Unused)},
+		{"undefined", "names that are not declared", true, fromAnalysis(analysis.Undefined)},
+		{"shadow", "declarations that hide a builtin or an enclosing declaration", false, fromAnalysis(analysis.Shadowed)},
+		{"macro-quote", "macros whose body never calls quote()", true, macroQuote},
+		{"exhaustive", "match expressions that some values fall through", true, fromAnalysis(analysis.NonExhaustive)},
+	}
+}
+
+// LookupRule returns the rule called name, or nil
+func LookupRule(name string) *Rule {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Config turns rules on and off. It is read from JSON such as
+//
+//	{"rules": {"shadow": true, "unused": false}}
+type Config struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LoadConfig reads a configuration file, rejecting rules that do not exist
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for name := range config.Rules {
+		if LookupRule(name) == nil {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, name)
+		}
+	}
+	return config, nil
+}
+
+// Enabled reports whether rule runs under c. A nil Config runs the default rules.
+func (c *Config) Enabled(rule *Rule) bool {
+	if c != nil {
+		if enabled, ok := c.Rules[rule.Name]; ok {
+			return enabled
+		}
+	}
+	return rule.Default
+}
+
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.File, d.Line, d.Column, d.Message, d.Rule)
+}
+
+// pass holds what the rules need while checking one program
+type pass struct {
+	file        string
+	program     *ast.Program
+	info        *analysis.Info
+	rule        *Rule
+	diagnostics []Diagnostic
+}
+
+func (p *pass) report(pos token.Position, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		File:    p.file,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Rule:    p.rule.Name,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Check runs the rules enabled by config over a parsed program. file only labels the
+// diagnostics, which are sorted by position.
+func Check(file string, program *ast.Program, src string, config *Config) []Diagnostic {
+	p := &pass{file: file, program: program, info: analysis.Analyze(program, src)}
+	for _, rule := range Rules {
+		if config.Enabled(rule) {
+			p.rule = rule
+			rule.run(p)
+		}
+	}
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		a, b := p.diagnostics[i], p.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return p.diagnostics
+}
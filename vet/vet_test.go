@@ -0,0 +1,96 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/josh-weston/go_interpreter/lexer"
+	"github.com/josh-weston/go_interpreter/parser"
+)
+
+func check(t *testing.T, src string, config *Config) []Diagnostic {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Check("test.monkey", program, src, config)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn(x) { return x; x + 1 }; f(1)", []string{"test.monkey:1:27: unreachable code (unreachable)"}},
+		{"let f = fn(x) { if (x) { return 1 } else { return 2 }; 3 }; f(1)",
+			[]string{"test.monkey:1:56: unreachable code (unreachable)"}},
+		// only one branch returns
+		{"let f = fn(x) { if (x) { return 1 }; 3 }; f(1)", nil},
+		{"if (1 < 2) { 1 }", []string{"test.monkey:1:5: condition is always true (constant-condition)"}},
+		{"if (!true) { 1 }", []string{"test.monkey:1:5: condition is always false (constant-condition)"}},
+		{"let x = 1; if (x < 2) { 1 }", nil},
+		{"if (null ?? 1) { 1 }", []string{"test.monkey:1:5: condition is always true (constant-condition)"}},
+		{"if (1 / 0) { 1 }", nil},
+		{"if (4 / 2 == 2) { 1 }", []string{"test.monkey:1:5: condition is always true (constant-condition)"}},
+		{"let x = 1; x == x", []string{"test.monkey:1:12: comparison of x with itself is always true (self-comparison)"}},
+		{"let a = [1]; a[0] != a[0]", []string{"test.monkey:1:14: comparison of (a[0]) with itself is always false (self-comparison)"}},
+		// a call may give a different result each time
+		{"let f = fn() { 1 }; f() == f()", nil},
+		{"len(1, 2)", []string{"test.monkey:1:1: wrong number of arguments to len: got=2, want=1 (builtin-args)"}},
+		{"len(1)", []string{"test.monkey:1:5: argument value of len must be STRING or ARRAY, got INTEGER (builtin-args)"}},
+		{"push(\"a\", 1)", []string{"test.monkey:1:6: argument array of push must be ARRAY, got STRING (builtin-args)"}},
+		{"let args = [1, 2]; len(...args)", nil},
+		{"int([1]); float(\"1.5\")", []string{"test.monkey:1:5: argument value of int must be INTEGER or FLOAT or STRING or BOOLEAN, got ARRAY (builtin-args)"}},
+		{"puts(); puts(1, \"a\")", nil},
+		// a declaration named like a builtin is not checked
+		{"let len = fn(a, b) { a }; len(1, 2)", nil},
+		{"let f = fn() { let y = 1; 2 }; f()", []string{"test.monkey:1:20: y declared but not used (unused)"}},
+		{"z", []string{"test.monkey:1:1: undefined: z (undefined)"}},
+		{"let m = macro(a) { a };", []string{"test.monkey:1:9: macro never returns quote() (macro-quote)"}},
+		{"let m = macro(a) { quote(unquote(a)) };", nil},
+		{"let f = fn(b) { match (b) { true => 1 } }; f(true)",
+			[]string{"test.monkey:1:17: match is not exhaustive: false is not matched (exhaustive)"}},
+		{"let f = fn(b) { match (b) { true => 1, _ => 0 } }; f(true)", nil},
+		// shadowing is off unless enabled
+		{"let x = 1; let f = fn(x) { x }; f(x)", nil},
+	}
+
+	for _, tt := range tests {
+		diagnostics := check(t, tt.input, nil)
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%q: expected %d diagnostics, got %v", tt.input, len(tt.expected), diagnostics)
+			continue
+		}
+		for i, want := range tt.expected {
+			if got := diagnostics[i].String(); got != want {
+				t.Errorf("%q: expected %q, got %q", tt.input, want, got)
+			}
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFile)
+	if err := os.WriteFile(path, []byte(`{"rules": {"shadow": true, "self-comparison": false}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := check(t, "let x = 1; let f = fn(x) { x == x }; f(x)", config)
+	if len(diagnostics) != 1 || diagnostics[0].Rule != "shadow" {
+		t.Errorf("expected only the shadow rule to report, got %v", diagnostics)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": {"no-such-rule": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+}